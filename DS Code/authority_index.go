@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+// manufacturerBucket maps each authorized WMI to its Manufacturer, plus the
+// chain authority from the genesis block under authorityKey
+const manufacturerBucket = "manufacturers"
+const authorityKey = "authority"
+
+// roleBucket holds the roles granted by the chain authority, keyed by role,
+// a slash and the holder's public key, with the holder's name as the value
+const roleBucket = "roles"
+
+// Roles the chain authority can grant with a RoleGrant. The authority itself
+// always acts as the DMV.
+const (
+	roleDMV            = "dmv"
+	roleInsurer        = "insurer"
+	roleLawEnforcement = "law-enforcement"
+	roleServiceStation = "service-station"
+)
+
+var grantableRoles = []string{roleDMV, roleInsurer, roleLawEnforcement, roleServiceStation}
+
+func validRole(role string) bool {
+	for _, r := range grantableRoles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func roleKey(role string, key []byte) []byte {
+	return append([]byte(role+"/"), key...)
+}
+
+// Manufacturer is an identity the chain authority has allowed to issue VINs
+// starting with WMI, the three-character World Manufacturer Identifier
+type Manufacturer struct {
+	WMI       string
+	Name      string
+	PublicKey []byte
+}
+
+func (m *Manufacturer) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	if err := encoder.Encode(m); err != nil {
+		log.Panic(err)
+	}
+
+	return result.Bytes()
+}
+
+func DeserializeManufacturer(data []byte) (*Manufacturer, error) {
+	var m Manufacturer
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manufacturer: %w", err)
+	}
+
+	return &m, nil
+}
+
+// Authority returns the key allowed to authorize manufacturers, or nil on
+// chains created before authorities existed, where registration stays open
+func (bc *Blockchain) Authority() []byte {
+	var authority []byte
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		if key := tx.Bucket([]byte(manufacturerBucket)).Get([]byte(authorityKey)); key != nil {
+			authority = append([]byte{}, key...)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return authority
+}
+
+// FindManufacturer returns the manufacturer authorized for wmi, or nil if there is none
+func (bc *Blockchain) FindManufacturer(wmi string) *Manufacturer {
+	var m *Manufacturer
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(manufacturerBucket)).Get([]byte(wmi))
+		if data == nil {
+			return nil
+		}
+
+		var err error
+		m, err = DeserializeManufacturer(data)
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return m
+}
+
+// HasRole reports whether key has been granted role. The chain authority is
+// always the DMV.
+func (bc *Blockchain) HasRole(role string, key []byte) bool {
+	if role == roleDMV && len(key) > 0 && bytes.Equal(key, bc.Authority()) {
+		return true
+	}
+
+	var granted bool
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		granted = tx.Bucket([]byte(roleBucket)).Get(roleKey(role, key)) != nil
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return granted
+}
+
+// putAuthority records the genesis block's authority, if it names one
+func putAuthority(tx *bolt.Tx, authority []byte) error {
+	if len(authority) == 0 {
+		return nil
+	}
+
+	return tx.Bucket([]byte(manufacturerBucket)).Put([]byte(authorityKey), authority)
+}
+
+// putManufacturer adds an authorized manufacturer to the index
+func putManufacturer(tx *bolt.Tx, ma *ManufacturerAuthorization) error {
+	m := &Manufacturer{WMI: ma.WMI, Name: ma.Name, PublicKey: ma.Manufacturer}
+
+	return tx.Bucket([]byte(manufacturerBucket)).Put([]byte(ma.WMI), m.Serialize())
+}
+
+// putRole records a role granted by the chain authority
+func putRole(tx *bolt.Tx, rg *RoleGrant) error {
+	return tx.Bucket([]byte(roleBucket)).Put(roleKey(rg.Role, rg.Grantee), []byte(rg.Name))
+}
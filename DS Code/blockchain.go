@@ -2,74 +2,178 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/gob"
 	"fmt"
 	"math"
-	"reflect"
-	"time"
 )
 
-func NewBlock(transactions []Transaction, prevBlockHash []byte) *Block {
-	block := &Block{time.Now().Unix(), nil, transactions, prevBlockHash, []byte{}, 0}
-	pow := NewProofOfWork(block)
-	hash := pow.Run()
-	block.Hash = hash[:]
+func NewBlock(ctx context.Context, transactions []Transaction, prevBlockHash []byte, height int, timestamp int64, targetBits int, progress MiningProgress) (*Block, error) {
+	block := &Block{
+		Timestamp:     timestamp,
+		Transactions:  transactions,
+		PrevBlockHash: prevBlockHash,
+		Height:        height,
+		TargetBits:    targetBits,
+	}
+	if err := block.mine(ctx, progress); err != nil {
+		return nil, err
+	}
 
-	transactionTypes := make([]string, len(transactions))
-	for i, tx := range transactions {
-		transactionTypes[i] = reflect.TypeOf(tx).Elem().Name() // Gets the type name of the transaction
-		println("Transaction types : ", transactionTypes[i])
+	return block, nil
+}
+
+// mine commits the block to its transactions and searches for a nonce that
+// meets its target
+func (b *Block) mine(ctx context.Context, progress MiningProgress) error {
+	b.MerkleRoot = b.HashTransactions()
+	pow := NewProofOfWork(b)
+	pow.Progress = progress
+	hash, err := pow.Run(ctx)
+	if err != nil {
+		return err
+	}
+	b.Hash = hash[:]
+
+	transactionTypes := make([]string, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		transactionTypes[i] = transactionType(tx)
 	}
-	block.Transaction_types = transactionTypes
-	return block
+	b.Transaction_types = transactionTypes
+	return nil
 }
 
 var (
 	maxNonce = math.MaxInt64
 )
 
+// TransactionHash returns the hash a transaction contributes to the Merkle tree
+func TransactionHash(tx Transaction) []byte {
+	hash := sha256.Sum256(tx.Serialize())
+	return hash[:]
+}
+
+// transactionHashes returns the Merkle leaves of the block, in order
+func (b *Block) transactionHashes() [][]byte {
+	var txHashes [][]byte
+
+	for _, tx := range b.Transactions {
+		txHashes = append(txHashes, TransactionHash(tx))
+	}
+
+	return txHashes
+}
+
+// HashTransactions returns the Merkle root over the hashes of the block's transactions
+func (b *Block) HashTransactions() []byte {
+	mTree := NewMerkleTree(b.transactionHashes())
+
+	return mTree.RootNode.Data
+}
+
+// HasDuplicateTransactions reports whether the block holds the same
+// transaction twice, which would let it share a Merkle root with a
+// different list of transactions
+func (b *Block) HasDuplicateTransactions() bool {
+	return hasDuplicateLeaves(b.transactionHashes())
+}
+
+// MerkleProof returns the inclusion proof for the transaction at index
+func (b *Block) MerkleProof(index int) []MerkleProofStep {
+	return NewMerkleProof(b.transactionHashes(), index)
+}
+
+// Serialize returns the block's canonical encoding, described in encoding.go
 func (b *Block) Serialize() []byte {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
+	e := &encoder{}
+	e.writeByte(encodingVersion)
+	e.writeInt64(b.Timestamp)
+	e.writeBytes(b.PrevBlockHash)
+	e.writeBytes(b.MerkleRoot)
+	e.writeInt(b.Height)
+	e.writeInt(b.TargetBits)
+	e.writeInt(b.Nonce)
+	e.writeBytes(b.Hash)
+
+	// Each transaction carries its own version byte and type name
+	e.writeUint32(uint32(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		e.writeBytes(tx.Serialize())
+	}
 
-	// Serialize each transaction individually and collect the results
-	serializedTxs := make([][]byte, len(b.Transactions))
-	for i, tx := range b.Transactions {
-		serializedTx := tx.Serialize()
-		serializedTxs[i] = serializedTx
+	return e.Bytes()
+}
+
+// DeserializeBlock decodes a stored block, falling back to the legacy gob
+// format for databases that have not been migrated
+func DeserializeBlock(data []byte) (*Block, error) {
+	if !isCanonical(data) {
+		return deserializeLegacyBlock(data)
 	}
 
-	// Create a struct that holds all block data, including serialized transactions
-	tempBlock := struct {
-		Timestamp         int64
-		Transaction_types []string
-		Transactions      [][]byte
-		PrevBlockHash     []byte
-		Hash              []byte
-		Nonce             int
-	}{
-		Timestamp:         b.Timestamp,
-		Transaction_types: b.Transaction_types,
-		Transactions:      serializedTxs,
-		PrevBlockHash:     b.PrevBlockHash,
-		Hash:              b.Hash,
-		Nonce:             b.Nonce,
+	d := &decoder{data: data}
+	if version := d.readByte(); version != encodingVersion {
+		return nil, fmt.Errorf("unsupported block encoding version %d", version)
+	}
+	block := &Block{
+		Timestamp:     d.readInt64(),
+		PrevBlockHash: d.readBytes(),
+		MerkleRoot:    d.readBytes(),
+		Height:        d.readInt(),
+		TargetBits:    d.readInt(),
+		Nonce:         d.readInt(),
+		Hash:          d.readBytes(),
 	}
 
-	// Encode the temporary block structure
-	if err := encoder.Encode(tempBlock); err != nil {
-		return nil
+	count := d.readUint32()
+	for i := uint32(0); i < count && d.err == nil; i++ {
+		txData := d.readBytes()
+		if d.err != nil {
+			break
+		}
+
+		txType, err := encodedTransactionType(txData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode transaction %d: %w", i, err)
+		}
+
+		tx, err := decodeTransaction(txType, txData)
+		if err != nil {
+			return nil, err
+		}
+
+		block.Transaction_types = append(block.Transaction_types, txType)
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, fmt.Errorf("failed to decode block: %w", err)
 	}
 
-	return result.Bytes()
+	return block, nil
 }
 
-func DeserializeBlock(data []byte) (*Block, error) {
+// encodedTransactionType reads the type name from a transaction's canonical encoding
+func encodedTransactionType(data []byte) (string, error) {
+	d := &decoder{data: data}
+	d.readByte()
+	txType := d.readString()
+
+	return txType, d.err
+}
+
+// deserializeLegacyBlock decodes a block stored with encoding/gob before the
+// canonical format existed
+func deserializeLegacyBlock(data []byte) (*Block, error) {
 	var tempBlock struct {
 		Timestamp         int64
 		Transaction_types []string
 		Transactions      [][]byte
 		PrevBlockHash     []byte
+		MerkleRoot        []byte
+		Height            int
+		TargetBits        int
 		Hash              []byte
 		Nonce             int
 	}
@@ -82,29 +186,9 @@ func DeserializeBlock(data []byte) (*Block, error) {
 	// Deserialize each transaction based on its type
 	transactions := make([]Transaction, len(tempBlock.Transactions))
 	for i, txData := range tempBlock.Transactions {
-		txType := tempBlock.Transaction_types[i]
-		var tx Transaction
-		//println("DECODING TYPE ", txType)
-		switch txType {
-		case "VehicleRegistration":
-			tx = &VehicleRegistration{}
-			break
-		case "VehicleSale":
-			tx = &VehicleSale{}
-			break
-		case "LoanContract":
-			tx = &LoanContract{}
-			break
-		case "genesis":
-			tx = &genesis{}
-			break
-		default:
-			println("Unknown transaction")
-		}
-
-		if err := gob.NewDecoder(bytes.NewReader(txData)).Decode(tx); err != nil {
-			println("Error while decoding transaction")
-			return nil, fmt.Errorf("failed to decode transaction: %w", err)
+		tx, err := decodeTransaction(tempBlock.Transaction_types[i], txData)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
 		}
 
 		transactions[i] = tx
@@ -116,9 +200,35 @@ func DeserializeBlock(data []byte) (*Block, error) {
 		Transaction_types: tempBlock.Transaction_types,
 		Transactions:      transactions,
 		PrevBlockHash:     tempBlock.PrevBlockHash,
+		MerkleRoot:        tempBlock.MerkleRoot,
+		Height:            tempBlock.Height,
+		TargetBits:        tempBlock.TargetBits,
 		Hash:              tempBlock.Hash,
 		Nonce:             tempBlock.Nonce,
+		legacy:            true,
 	}
 
 	return block, nil
 }
+
+// decodeTransaction rebuilds a transaction of the named type from either its
+// canonical or its legacy gob encoding
+func decodeTransaction(txType string, txData []byte) (Transaction, error) {
+	tx, err := NewTransaction(txType)
+	if err != nil {
+		return nil, err
+	}
+
+	if isCanonical(txData) {
+		if err := tx.Deserialize(txData); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", txType, err)
+		}
+		return tx, nil
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(txData)).Decode(tx); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+
+	return tx, nil
+}
@@ -1,253 +1,800 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"Take1_Autochain/vin"
 )
 
 type CLI struct {
-	bc *Blockchain
+	bc     *Blockchain
+	format string // Output of read commands: formatText, formatJSON or formatNDJSON
 }
 
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  addblock -tx TYPE [OPTIONS] - add a block with specified transaction to the blockchain")
+	fmt.Println("Usage: [-format text|json|ndjson] COMMAND [OPTIONS]")
+	fmt.Println("  -format sets how read commands print; ndjson streams printchain one block per line")
+	fmt.Println("  createblockchain -authority AUTHORITY - create a new blockchain whose authority authorizes manufacturers")
+	fmt.Println("  createidentity -name NAME - generate a key pair to sign transactions with")
+	fmt.Println("  addblock TYPE [OPTIONS] - queue a transaction of the specified type for the next block")
 	fmt.Println("    Transaction types and options:")
-	fmt.Println("      VehicleRegistration -vin VIN -owner OWNER -date DATE")
-	fmt.Println("      VehicleSale -vin VIN -dealer DEALER -buyer BUYER -date DATE -price PRICE")
-	fmt.Println("      LoanContract -vin VIN -borrower BORROWER -lender LENDER -amount AMOUNT -start START -end END")
-	fmt.Println("  printchain - print all the blocks of the blockchain")
+	for _, info := range cliTransactionTypes() {
+		fmt.Printf("      %s %s\n", info.name, info.cli.Usage)
+	}
+	fmt.Println("    Signing parties are local identities; other parties may also be given as hex public keys")
+	fmt.Println("  mine - pack all pending transactions into a new block")
+	fmt.Println("  printchain - print all the blocks of the blockchain, newest first")
+	fmt.Println("  history -vin VIN - show every record for a vehicle, oldest first, with its ownership timeline")
+	fmt.Println("  coverage -vin VIN [-date DATE] - show the insurance policies covering a vehicle on a date, today by default")
+	fmt.Println("  loanschedule -vin VIN [-loan HASH] - print the amortization table and outstanding balance of a vehicle's loans")
+	fmt.Println("  inventory -owner OWNER - list the vehicles a party owns right now")
+	fmt.Println("  stolen - list the vehicles with an open theft report")
+	fmt.Println("  report [-from DATE] [-to DATE] [-csv] - summarize sales per dealer per month: volume, revenue, prices, days in inventory and loan penetration")
+	fmt.Println("  recalls -vin VIN - list the open and remedied recalls affecting a vehicle")
+	fmt.Println("  migrate - re-encode and re-mine a legacy gob database in the canonical format")
+	fmt.Println("  verifychain - replay the chain from genesis and report the first invalid block or transaction")
+	fmt.Println("  startnode [-addr ADDR] [-mine-interval DURATION] - serve peers and mine the pending pool periodically")
 }
 
 func (cli *CLI) addBlock(txType string, args []string) {
-	switch txType {
-	case "VehicleRegistration":
-		cli.addVehicleRegistration(args)
-	case "VehicleSale":
-		cli.addVehicleSale(args)
-	case "LoanContract":
-		cli.addLoanContract(args)
-	default:
-		fmt.Println("Unsupported transaction type")
+	info, ok := transactionRegistry[txType]
+	if !ok || info.cli == nil {
+		fmt.Println("Unsupported transaction type:", txType)
 		cli.printUsage()
+		os.Exit(1)
 	}
+
+	if len(args) == 0 {
+		cli.printUsage()
+		os.Exit(1)
+	}
+
+	info.cli.Run(cli, args)
 }
 
-func (cli *CLI) addVehicleRegistration(args []string) {
-	layout := "2006-01-02"
-	cmd := flag.NewFlagSet("VehicleRegistration", flag.ExitOnError)
-	vin := cmd.String("vin", "", "Vehicle Identification Number")
-	owner := cmd.String("owner", "", "Owner's name or identifier")
-	date := cmd.String("date", "", "Registration date as UNIX timestamp")
+// identity returns the local key pair stored under name, exiting if there is none
+func (cli *CLI) identity(name string) *Identity {
+	ids, err := LoadIdentities()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	id := ids.Get(name)
+	if id == nil {
+		fmt.Printf("Error: No local identity named %q. Create one with createidentity.\n", name)
+		os.Exit(1)
+	}
+
+	return id
+}
+
+// party resolves a local identity name or a hex public key, exiting if it is neither
+func (cli *CLI) party(name string) []byte {
+	ids, err := LoadIdentities()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	key, err := ids.ResolveParty(name)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	return key
+}
+
+// jsonOutput reports whether read commands should print JSON
+func (cli *CLI) jsonOutput() bool {
+	return cli.format == formatJSON || cli.format == formatNDJSON
+}
+
+// printJSON writes v as an indented JSON document, or on one line for ndjson
+func (cli *CLI) printJSON(v any) {
+	var out []byte
+	var err error
+	if cli.format == formatNDJSON {
+		out, err = json.Marshal(v)
+	} else {
+		out, err = json.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(string(out))
+}
+
+// checkVIN exits with the reason if s is not a valid VIN
+func (cli *CLI) checkVIN(s string) {
+	if err := vin.Validate(s); err != nil {
+		fmt.Printf("Error: invalid VIN %s: %v\n", s, err)
+		os.Exit(1)
+	}
+}
+
+// partyName renders a public key as hex, prefixed with its local identity name when known
+func (cli *CLI) partyName(key []byte) string {
+	ids, err := LoadIdentities()
+	if err == nil {
+		for name, id := range ids.Identities {
+			if bytes.Equal(id.PublicKey, key) {
+				return fmt.Sprintf("%s (%x)", name, key)
+			}
+		}
+	}
+
+	return fmt.Sprintf("%x", key)
+}
+
+func (cli *CLI) createBlockchain(args []string) {
+	cmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	authority := cmd.String("authority", "", "Identity or public key of the authority that authorizes manufacturers")
 
-	// Parse the provided arguments according to the defined flags
 	err := cmd.Parse(args)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	// Validate VIN
-	if *vin == "" {
-		fmt.Println("A valid VIN is required.")
+	if *authority == "" {
+		fmt.Println("An authority's identifier is required.")
 		cmd.Usage()
 		os.Exit(1)
 	}
 
-	// Validate owner
-	if *owner == "" {
-		fmt.Println("An owner's identifier is required.")
+	key := cli.party(*authority)
+	bc := CreateBlockchain(key)
+	bc.db.Close()
+
+	fmt.Printf("Created a new blockchain with authority %x\n", key)
+}
+
+func (cli *CLI) createIdentity(args []string) {
+	cmd := flag.NewFlagSet("createidentity", flag.ExitOnError)
+	name := cmd.String("name", "", "Local name for the new identity")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if *name == "" {
+		fmt.Println("A name for the identity is required.")
 		cmd.Usage()
 		os.Exit(1)
 	}
 
-	// Validate registration date
-	date_validated, err := time.Parse(layout, *date)
+	ids, err := LoadIdentities()
 	if err != nil {
-		log.Panic("Invalid start date format. Use YYYY-MM-DD.")
+		log.Panic(err)
+	}
+	if ids.Get(*name) != nil {
+		fmt.Printf("Error: An identity named %q already exists.\n", *name)
+		os.Exit(1)
 	}
 
-	// Create the VehicleRegistration transaction
-	vr := &VehicleRegistration{VIN: *vin, Owner: []byte(*owner), RegistrationDate: date_validated.Unix()}
+	id := NewIdentity(*name)
+	ids.Identities[*name] = id
+	ids.SaveToFile()
 
-	// Add the transaction to the blockchain
-	cli.bc.AddBlock([]Transaction{vr})
+	fmt.Printf("Created identity %s with public key %x\n", *name, id.PublicKey)
+}
 
-	fmt.Println("Vehicle registration transaction added successfully!")
+// submit checks a transaction against the chain and everything already
+// pending, then queues it in the mempool
+func (cli *CLI) submit(t Transaction) {
+	view := newLedgerView(cli.bc)
+	for _, pending := range cli.bc.PendingTransactions() {
+		if view.check(pending) == nil {
+			view.apply(pending)
+		}
+	}
+
+	if err := view.check(t); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	cli.bc.AddToMempool(t)
 }
 
-func (cli *CLI) addVehicleSale(args []string) {
-	layout := "2006-01-02"
-	cmd := flag.NewFlagSet("VehicleSale", flag.ExitOnError)
-	vin := cmd.String("vin", "", "Vehicle Identification Number")
-	dealer := cmd.String("dealer", "", "Dealer's identifier")
-	buyer := cmd.String("buyer", "", "Buyer's identifier")
-	date := cmd.String("date", "", "Sale date as UNIX timestamp")
-	price := cmd.Int("price", 0, "Sale price")
+func (cli *CLI) mine() {
+	progress := func(hashes uint64, hashRate float64) {
+		fmt.Printf("\rMining: %d hashes, %.0f H/s", hashes, hashRate)
+	}
+
+	block, rejected, err := cli.bc.MinePendingTransactions(context.Background(), progress)
+	fmt.Println()
+	for _, err := range rejected {
+		fmt.Println("Rejected:", err)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	if block == nil {
+		fmt.Println("No valid pending transactions to mine.")
+		return
+	}
+
+	fmt.Printf("Mined block %x with %d transactions.\n", block.Hash, len(block.Transactions))
+}
+
+func (cli *CLI) startNode(args []string) {
+	cmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	addr := cmd.String("addr", ":8080", "Address to listen on for peers")
+	interval := cmd.Duration("mine-interval", 30*time.Second, "How often to mine the pending pool (0 disables)")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	startNode(cli.bc, *addr, *interval)
+}
+func (cli *CLI) printChain() {
+	bci := cli.bc.Iterator()
+
+	if cli.jsonOutput() {
+		blocks := []BlockJSON{}
+		for {
+			block := bci.Next()
+			// Stream each block as soon as it is read
+			if cli.format == formatNDJSON {
+				cli.printJSON(newBlockJSON(cli.bc, block))
+			} else {
+				blocks = append(blocks, newBlockJSON(cli.bc, block))
+			}
+			if len(block.PrevBlockHash) == 0 {
+				break
+			}
+		}
+		if cli.format == formatJSON {
+			cli.printJSON(struct {
+				Blocks []BlockJSON `json:"blocks"`
+			}{blocks})
+		}
+		return
+	}
+
+	for {
+		block := bci.Next()
+
+		fmt.Printf("Prev. hash: %x\n", block.PrevBlockHash)
+		fmt.Printf("Merkle root: %x\n", block.MerkleRoot)
+
+		for _, tx := range block.Transactions {
+			tx.print_transaction()
+			if info := decodeVIN(tx); info != nil {
+				fmt.Printf("VIN details: %s\n", info)
+			}
+			fmt.Printf("Tx hash: %x\n", TransactionHash(tx))
+			fmt.Println()
+		}
+		fmt.Printf("Height: %d\n", block.Height)
+		fmt.Printf("Target bits: %d\n", block.TargetBits)
+		fmt.Printf("Hash: %x\n", block.Hash)
+		pow := NewProofOfWork(block)
+		fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate(cli.bc.TargetBitsAfter(block.PrevBlockHash))))
+		fmt.Println()
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+}
+
+func (cli *CLI) history(args []string) {
+	cmd := flag.NewFlagSet("history", flag.ExitOnError)
+	vehicleVIN := cmd.String("vin", "", "Vehicle Identification Number")
 
 	err := cmd.Parse(args)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	// Validate VIN
-	if *vin == "" {
+	if *vehicleVIN == "" {
 		fmt.Println("A valid VIN is required.")
 		cmd.Usage()
 		os.Exit(1)
 	}
+	cli.checkVIN(*vehicleVIN)
 
-	// Validate dealer and buyer
-	if *dealer == "" || *buyer == "" {
-		fmt.Println("Both dealer and buyer identifiers are required.")
-		cmd.Usage()
+	entries, err := cli.bc.VehicleHistory(*vehicleVIN)
+	if err != nil {
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
+	record := cli.bc.FindVehicle(*vehicleVIN)
+	timeline := OwnershipTimeline(entries)
+	liens := LienChain(record)
+	details := decodeVIN(entries[0].Transaction)
+
+	if cli.jsonOutput() {
+		cli.printJSON(struct {
+			VIN       string            `json:"vin"`
+			State     string            `json:"state"`
+			Details   *vin.Info         `json:"details,omitempty"`
+			Brands    []string          `json:"brands,omitempty"`
+			Records   []HistoryEntry    `json:"records"`
+			Ownership []OwnershipPeriod `json:"ownership"`
+			Liens     []LienLink        `json:"liens,omitempty"`
+		}{*vehicleVIN, record.State().String(), details, record.Brands, entries, timeline, liens})
+		return
+	}
+
+	fmt.Printf("History for VIN %s (%d records)\n", *vehicleVIN, len(entries))
+	if record.Deregistered != "" {
+		fmt.Printf("State: %s (%s)\n", record.State(), record.Deregistered)
+	} else {
+		fmt.Printf("State: %s\n", record.State())
+	}
+	if details != nil {
+		fmt.Printf("VIN details: %s\n", details)
+	}
+	if len(record.Brands) > 0 {
+		fmt.Printf("Title brands: %s\n", strings.Join(record.Brands, ", "))
+	}
+	fmt.Println()
+	for _, entry := range entries {
+		fmt.Printf("Block %d (%x) at %s\n", entry.BlockHeight, entry.BlockHash, entry.BlockTime.Format(time.RFC3339))
+		entry.Transaction.print_transaction()
+		fmt.Println()
+	}
+
+	fmt.Println("Ownership timeline:")
+	for _, period := range timeline {
+		until := "present"
+		if period.To != nil {
+			until = period.To.Format("2006-01-02")
+		}
+		fmt.Printf("  %s  %s to %s  via %s", cli.partyName(period.Owner), period.From.Format("2006-01-02"), until, period.Acquired)
+		if period.Price > 0 {
+			fmt.Printf(" for %d", period.Price)
+		}
+		fmt.Println()
+	}
+
+	if len(liens) > 0 {
+		fmt.Println("Lien chain:")
+	}
+	for _, link := range liens {
+		fmt.Printf("  %x  %d from %s to %s, %d paid, %s\n",
+			link.Loan, link.Amount, cli.partyName(link.Lender), cli.partyName(link.Borrower), link.Paid, link.Status)
+		if link.Refinances != nil {
+			fmt.Printf("    took over %x\n", link.Refinances)
+		}
+	}
+}
+
+func (cli *CLI) loanSchedule(args []string) {
+	cmd := flag.NewFlagSet("loanschedule", flag.ExitOnError)
+	vehicleVIN := cmd.String("vin", "", "Vehicle Identification Number")
+	loanHash := cmd.String("loan", "", "Hash of one loan contract to show; all of the vehicle's loans by default")
 
-	// Validate sale price
-	if *price <= 0 {
-		fmt.Println("Sale price must be greater than 0.")
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if *vehicleVIN == "" {
+		fmt.Println("A valid VIN is required.")
 		cmd.Usage()
 		os.Exit(1)
 	}
+	cli.checkVIN(*vehicleVIN)
 
-	date_validated, err := time.Parse(layout, *date)
-	if err != nil {
-		log.Panic("Invalid start date format. Use YYYY-MM-DD.")
+	record := cli.bc.FindVehicle(*vehicleVIN)
+	if record == nil {
+		record = &VehicleRecord{VIN: *vehicleVIN}
+	}
+
+	now := time.Now().Unix()
+	if cli.jsonOutput() {
+		type loanJSON struct {
+			Loan              HexBytes      `json:"loan"`
+			Status            string        `json:"status"`
+			Refinances        HexBytes      `json:"refinances,omitempty"`
+			Contract          any           `json:"contract"`
+			InstallmentAmount int           `json:"installmentAmount,omitempty"`
+			Schedule          []Installment `json:"schedule,omitempty"`
+			Paid              int           `json:"paid"`
+			Payments          []any         `json:"payments"`
+			Outstanding       *int          `json:"outstanding,omitempty"` // As of today; left out once released
+		}
+
+		loans := []loanJSON{}
+		for _, lien := range record.Liens {
+			if *loanHash != "" && fmt.Sprintf("%x", lien.Loan) != *loanHash {
+				continue
+			}
+			loan := loanJSON{lien.Loan, lien.Status(), lien.Refinances, lien.Contract.jsonView(),
+				lien.Contract.InstallmentAmount(), lien.Contract.Schedule(), lien.Paid, []any{}, nil}
+			for _, p := range lien.Payments {
+				loan.Payments = append(loan.Payments, p.jsonView())
+			}
+			if !lien.Released {
+				outstanding := lien.Outstanding(now)
+				loan.Outstanding = &outstanding
+			}
+			loans = append(loans, loan)
+		}
+		cli.printJSON(loans)
+		return
+	}
+
+	if len(record.Liens) == 0 {
+		fmt.Println("No loans are recorded against this vehicle.")
+		return
+	}
+
+	for _, lien := range record.Liens {
+		if *loanHash != "" && fmt.Sprintf("%x", lien.Loan) != *loanHash {
+			continue
+		}
+		lc := lien.Contract
+
+		fmt.Printf("Loan %x (%s)\n", lien.Loan, lien.Status())
+		if lien.Refinances != nil {
+			fmt.Printf("  Took over loan %x at the sale of the vehicle\n", lien.Refinances)
+		}
+		fmt.Printf("  Lender: %s\n", cli.partyName(lc.Lender))
+		fmt.Printf("  Borrower: %s\n", cli.partyName(lc.Borrower))
+		fmt.Printf("  Amount: %d from %s to %s\n", lc.LoanAmount,
+			time.Unix(lc.StartDate, 0).Format("2006-01-02"), time.Unix(lc.EndDate, 0).Format("2006-01-02"))
+
+		if lc.Installments == 0 {
+			fmt.Println("  No repayment schedule was agreed.")
+		} else {
+			fmt.Printf("  Terms: %s APR, %d %s payments of %d\n", formatAPR(lc.APR), lc.Installments, lc.PaymentFrequency, lc.InstallmentAmount())
+			fmt.Printf("  %4s  %-10s  %10s  %10s  %10s  %10s\n", "#", "Due", "Payment", "Interest", "Principal", "Balance")
+			for _, row := range lc.Schedule() {
+				fmt.Printf("  %4d  %-10s  %10d  %10d  %10d  %10d\n",
+					row.Number, row.DueDate.Format("2006-01-02"), row.Payment, row.Interest, row.Principal, row.Balance)
+			}
+		}
+
+		fmt.Printf("  Paid: %d in %d payments\n", lien.Paid, len(lien.Payments))
+		if !lien.Released {
+			fmt.Printf("  Outstanding as of today: %d\n", lien.Outstanding(now))
+		}
+		fmt.Println()
 	}
+}
 
-	currentOwner, err := cli.bc.FindLatestOwnerByVIN(*vin)
+func (cli *CLI) coverage(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("coverage", flag.ExitOnError)
+	vehicleVIN := cmd.String("vin", "", "Vehicle Identification Number")
+	date := cmd.String("date", "", "Date to check in YYYY-MM-DD format; today by default")
+
+	err := cmd.Parse(args)
 	if err != nil {
-		fmt.Println("Error: Could not find the vehicle with the specified VIN.")
+		log.Panic(err)
+	}
+
+	if *vehicleVIN == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
 		os.Exit(1)
 	}
+	cli.checkVIN(*vehicleVIN)
 
-	if string(currentOwner) != *dealer {
-		fmt.Println("Error: The dealer is not the current owner of the vehicle.")
+	at := time.Now()
+	if *date != "" {
+		if at, err = time.Parse(layout, *date); err != nil {
+			log.Panic("Invalid date format. Use YYYY-MM-DD.")
+		}
+	}
+
+	record := cli.bc.FindVehicle(*vehicleVIN)
+	if record == nil {
+		fmt.Println("Error: vehicle not found")
 		os.Exit(1)
 	}
 
-	// 2. Check if the vehicle is under any active loan.
-	if cli.hasActiveLoan(*vin) {
-		fmt.Println("Error: The vehicle is currently under an active loan and cannot be sold.")
+	policies := record.CoverageOn(at.Unix())
+	if cli.jsonOutput() {
+		type policyJSON struct {
+			Policy any   `json:"policy"`
+			Claims []any `json:"claims"`
+		}
+		covering := []policyJSON{}
+		for _, p := range policies {
+			entry := policyJSON{p.jsonView(), []any{}}
+			for _, c := range record.Claims {
+				if c.PolicyID == p.PolicyID {
+					entry.Claims = append(entry.Claims, c.jsonView())
+				}
+			}
+			covering = append(covering, entry)
+		}
+		cli.printJSON(struct {
+			VIN      string       `json:"vin"`
+			Date     ISODate      `json:"date"`
+			Policies []policyJSON `json:"policies"`
+		}{*vehicleVIN, ISODate(at.Unix()), covering})
+		return
+	}
+
+	if len(policies) == 0 {
+		fmt.Printf("VIN %s is not insured on %s.\n", *vehicleVIN, at.Format(layout))
+		return
+	}
+
+	fmt.Printf("VIN %s is insured on %s by:\n", *vehicleVIN, at.Format(layout))
+	for _, p := range policies {
+		fmt.Printf("  policy %s from %s, %s to %s\n", p.PolicyID, cli.partyName(p.Insurer),
+			time.Unix(p.StartDate, 0).Format(layout), time.Unix(p.EndDate, 0).Format(layout))
+		for _, c := range record.Claims {
+			if c.PolicyID == p.PolicyID {
+				fmt.Printf("    claim for a %s incident on %s, paid %d\n", c.Severity, time.Unix(c.IncidentDate, 0).Format(layout), c.Payout)
+			}
+		}
+	}
+}
+
+func (cli *CLI) inventory(args []string) {
+	cmd := flag.NewFlagSet("inventory", flag.ExitOnError)
+	owner := cmd.String("owner", "", "Owner's identity name or hex public key")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if *owner == "" {
+		fmt.Println("An owner's identifier is required.")
+		cmd.Usage()
 		os.Exit(1)
 	}
 
-	// Create the VehicleSale transaction
-	vs := &VehicleSale{
-		VIN:      *vin,
-		Dealer:   []byte(*dealer),
-		Buyer:    []byte(*buyer),
-		SaleDate: date_validated.Unix(),
-		Price:    *price,
+	party := cli.party(*owner)
+	items := cli.bc.Inventory(party)
+
+	if cli.jsonOutput() {
+		if items == nil {
+			items = []InventoryItem{}
+		}
+		cli.printJSON(items)
+		return
 	}
 
-	// Add the transaction to the blockchain
-	cli.bc.AddBlock([]Transaction{vs})
+	if len(items) == 0 {
+		fmt.Printf("%s owns no vehicles.\n", cli.partyName(party))
+		return
+	}
 
-	fmt.Println("Vehicle sale transaction added successfully!")
+	fmt.Printf("Vehicles owned by %s (%d):\n", cli.partyName(party), len(items))
+	for _, item := range items {
+		liens := "no liens"
+		if item.ActiveLiens > 0 {
+			liens = fmt.Sprintf("%d active liens", item.ActiveLiens)
+		}
+		fmt.Printf("  %s  acquired %s", item.VIN, time.Unix(int64(item.Acquired), 0).Format("2006-01-02"))
+		if item.PricePaid > 0 {
+			fmt.Printf(" for %d", item.PricePaid)
+		}
+		fmt.Printf(", %s, %s\n", liens, item.State)
+	}
 }
 
-func (cli *CLI) addLoanContract(args []string) {
+func (cli *CLI) stolen() {
+	records := cli.bc.StolenVehicles()
+	if cli.jsonOutput() {
+		reports := []any{}
+		for _, record := range records {
+			reports = append(reports, record.Theft.jsonView())
+		}
+		cli.printJSON(reports)
+		return
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No vehicles are reported stolen.")
+		return
+	}
 
+	fmt.Printf("Vehicles reported stolen (%d):\n", len(records))
+	for _, record := range records {
+		fmt.Printf("  %s  stolen %s, case %s reported by %s\n", record.VIN,
+			time.Unix(record.Theft.TheftDate, 0).Format("2006-01-02"), record.Theft.CaseNumber, cli.partyName(record.Theft.Issuer))
+	}
+}
+
+func (cli *CLI) recalls(args []string) {
 	layout := "2006-01-02"
-	cmd := flag.NewFlagSet("LoanContract", flag.ExitOnError)
-	vin := cmd.String("vin", "", "Vehicle Identification Number (VIN)")
-	borrower := cmd.String("borrower", "", "Borrower's identifier")
-	lender := cmd.String("lender", "", "Lender's identifier")
-	loanAmount := cmd.Int("amount", 0, "The amount of the loan")
-	startDateStr := cmd.String("start", "", "The start date of the loan in YYYY-MM-DD format")
-	endDateStr := cmd.String("end", "", "The end date of the loan in YYYY-MM-DD format")
+	cmd := flag.NewFlagSet("recalls", flag.ExitOnError)
+	vehicleVIN := cmd.String("vin", "", "Vehicle Identification Number")
 
 	err := cmd.Parse(args)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	// Validate VIN
-	if *vin == "" {
+	if *vehicleVIN == "" {
 		fmt.Println("A valid VIN is required.")
 		cmd.Usage()
 		os.Exit(1)
 	}
+	cli.checkVIN(*vehicleVIN)
+
+	open, remedied := cli.bc.Recalls(*vehicleVIN)
+	if cli.jsonOutput() {
+		views := func(notices []*RecallNotice) []any {
+			list := []any{}
+			for _, rn := range notices {
+				list = append(list, rn.jsonView())
+			}
+			return list
+		}
+		cli.printJSON(struct {
+			VIN      string `json:"vin"`
+			Open     []any  `json:"open"`
+			Remedied []any  `json:"remedied"`
+		}{*vehicleVIN, views(open), views(remedied)})
+		return
+	}
 
-	// Validate borrower and lender
-	if *borrower == "" || *lender == "" {
-		fmt.Println("Both borrower and lender identifiers are required.")
-		cmd.Usage()
-		os.Exit(1)
+	if len(open) == 0 {
+		fmt.Printf("VIN %s has no open recalls.\n", *vehicleVIN)
+	} else {
+		fmt.Printf("Open recalls on VIN %s:\n", *vehicleVIN)
+		for _, rn := range open {
+			fmt.Printf("  %s from %s, %s: %s\n", rn.Campaign, cli.partyName(rn.Manufacturer),
+				time.Unix(rn.NoticeDate, 0).Format(layout), rn.Description)
+		}
 	}
 
-	// Validate loan amount
-	if *loanAmount <= 0 {
-		fmt.Println("Loan amount must be greater than 0.")
-		cmd.Usage()
-		os.Exit(1)
+	if len(remedied) > 0 {
+		fmt.Println("Remedied:")
+		for _, rn := range remedied {
+			fmt.Printf("  %s: %s\n", rn.Campaign, rn.Description)
+		}
 	}
+}
 
-	startDate, err := time.Parse(layout, *startDateStr)
+func (cli *CLI) report(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("report", flag.ExitOnError)
+	from := cmd.String("from", "", "First sale date to include, in YYYY-MM-DD format")
+	to := cmd.String("to", "", "Last sale date to include, in YYYY-MM-DD format")
+	asCSV := cmd.Bool("csv", false, "Print the report as CSV")
+
+	err := cmd.Parse(args)
 	if err != nil {
-		log.Panic("Invalid start date format. Use YYYY-MM-DD.")
+		log.Panic(err)
 	}
-	endDate, err := time.Parse(layout, *endDateStr)
-	if err != nil {
-		log.Panic("Invalid end date format. Use YYYY-MM-DD.")
+
+	start, end := int64(math.MinInt64), int64(math.MaxInt64)
+	if *from != "" {
+		t, err := time.Parse(layout, *from)
+		if err != nil {
+			log.Panic("Invalid from date format. Use YYYY-MM-DD.")
+		}
+		start = t.Unix()
+	}
+	if *to != "" {
+		t, err := time.Parse(layout, *to)
+		if err != nil {
+			log.Panic("Invalid to date format. Use YYYY-MM-DD.")
+		}
+		end = t.Unix()
 	}
 
-	currentOwner, err := cli.bc.FindLatestOwnerByVIN(*vin)
+	rows, total, err := cli.bc.SalesReport(start, end)
 	if err != nil {
-		fmt.Println("Error: Could not find the vehicle with the specified VIN.")
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
 
-	if string(currentOwner) != *borrower {
-		fmt.Println("Error: The borrower is not the current owner of the vehicle.")
-		os.Exit(1)
+	if cli.jsonOutput() && !*asCSV {
+		if rows == nil {
+			rows = []SalesSummary{}
+		}
+		cli.printJSON(struct {
+			Rows  []SalesSummary `json:"rows"`
+			Total SalesSummary   `json:"total"`
+		}{rows, total})
+		return
 	}
 
-	// Create the LoanContract transaction
-	lc := &LoanContract{
-		VIN:        *vin,
-		Borrower:   []byte(*borrower),
-		Lender:     []byte(*lender),
-		LoanAmount: *loanAmount,
-		StartDate:  startDate.Unix(),
-		EndDate:    endDate.Unix(),
+	if *asCSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"dealer", "month", "sales", "revenue", "average_price", "median_price", "average_days_in_inventory", "loan_penetration"})
+		for _, row := range append(rows, total) {
+			dealer, month := fmt.Sprintf("%x", row.Dealer), row.Month
+			if row.Dealer == nil {
+				dealer, month = "total", ""
+			}
+			w.Write([]string{dealer, month, strconv.Itoa(row.Sales), strconv.Itoa(row.Revenue),
+				strconv.FormatFloat(row.AveragePrice, 'f', 2, 64), strconv.FormatFloat(row.MedianPrice, 'f', 2, 64),
+				strconv.FormatFloat(row.AverageDaysInInventory, 'f', 1, 64), strconv.FormatFloat(row.LoanPenetration, 'f', 4, 64)})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Panic(err)
+		}
+		return
 	}
 
-	// Add the transaction to the blockchain
-	cli.bc.AddBlock([]Transaction{lc})
+	if total.Sales == 0 {
+		fmt.Println("No sales in the period.")
+		return
+	}
 
-	fmt.Println("Loan contract transaction added successfully!")
-}
-func (cli *CLI) printChain() {
-	bci := cli.bc.Iterator()
+	line := func(label string, s SalesSummary) {
+		fmt.Printf("  %-8s %6d %12d %12.2f %12.2f %10.1f %8.1f%%\n", label, s.Sales, s.Revenue,
+			s.AveragePrice, s.MedianPrice, s.AverageDaysInInventory, s.LoanPenetration*100)
+	}
+	header := fmt.Sprintf("  %-8s %6s %12s %12s %12s %10s %9s", "Month", "Sales", "Revenue", "Avg price", "Median", "Days held", "Financed")
 
-	for {
-		block := bci.Next()
-		if block == nil {
-			fmt.Println("Reached the end of the blockchain or encountered an error.")
-			break
+	var dealer []byte
+	for _, row := range rows {
+		if !bytes.Equal(row.Dealer, dealer) {
+			dealer = row.Dealer
+			fmt.Printf("Dealer %s\n%s\n", cli.partyName(dealer), header)
 		}
+		line(row.Month, row)
+	}
+	fmt.Printf("All dealers\n%s\n", header)
+	line("Total", total)
+}
 
-		fmt.Printf("Prev. hash: %x\n", block.PrevBlockHash)
+func (cli *CLI) migrate() {
+	progress := func(hashes uint64, hashRate float64) {
+		fmt.Printf("\rMining: %d hashes, %.0f H/s", hashes, hashRate)
+	}
 
-		for _, tx := range block.Transactions {
-			tx.print_transaction()
-			fmt.Println()
-		}
-		fmt.Printf("Hash: %x\n", block.Hash)
-		pow := NewProofOfWork(block)
-		fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
-		fmt.Println()
+	count, err := cli.bc.Migrate(context.Background(), progress)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 
-		if len(block.PrevBlockHash) == 0 {
-			break
+	if count == 0 {
+		fmt.Println("The blockchain already uses the canonical encoding.")
+		return
+	}
+	fmt.Printf("Migrated %d blocks to the canonical encoding. New tip: %x\n", count, cli.bc.tip)
+}
+
+func (cli *CLI) verifyChain() {
+	count, err := cli.bc.VerifyChain()
+	if cli.jsonOutput() {
+		result := struct {
+			Valid  bool   `json:"valid"`
+			Blocks int    `json:"blocks,omitempty"`
+			Error  string `json:"error,omitempty"`
+		}{Valid: err == nil, Blocks: count}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		cli.printJSON(result)
+		if err != nil {
+			os.Exit(1)
 		}
+		return
 	}
+
+	if err != nil {
+		fmt.Println("Verification failed:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Verified %d blocks: hash links, proof of work, signatures and ownership rules are intact.\n", count)
 }
 
 func (cli *CLI) validateArgs() {
@@ -264,44 +811,68 @@ func (cli *CLI) validateArgs() {
 	}
 }
 
+// parseGlobalFlags reads the options given before the command and leaves
+// the command and its own options in os.Args
+func (cli *CLI) parseGlobalFlags() {
+	global := flag.NewFlagSet("global", flag.ExitOnError)
+	global.Usage = cli.printUsage
+	format := global.String("format", formatText, "Output format of read commands: text, json or ndjson")
+	global.Parse(os.Args[1:])
+
+	switch *format {
+	case formatText, formatJSON, formatNDJSON:
+		cli.format = *format
+	default:
+		fmt.Println("Error: -format must be text, json or ndjson.")
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], global.Args()...)
+}
+
 func (cli *CLI) Run() {
+	cli.parseGlobalFlags()
 	cli.validateArgs()
-	println(os.Args[1])
+
+	// These commands work on a chain created beforehand
 	switch os.Args[1] {
+	case "addblock", "mine", "startnode", "migrate", "verifychain", "history", "coverage", "loanschedule", "inventory", "stolen", "recalls", "report", "printchain":
+		cli.bc = NewBlockchain()
+		defer cli.bc.db.Close()
+	}
+
+	switch os.Args[1] {
+	case "createblockchain":
+		cli.createBlockchain(os.Args[2:])
 	case "addblock":
 		if len(os.Args) < 3 {
 			cli.printUsage()
 			os.Exit(1)
 		}
-		txType := os.Args[2]
-		switch txType {
-		case "VehicleRegistration":
-			if len(os.Args) < 4 {
-				cli.printUsage()
-				os.Exit(1)
-			}
-			cli.addVehicleRegistration(os.Args[3:])
-			break
-		case "VehicleSale":
-			if len(os.Args) < 4 {
-				cli.printUsage()
-				os.Exit(1)
-			}
-			cli.addVehicleSale(os.Args[3:])
-			break
-		case "LoanContract":
-			if len(os.Args) < 4 {
-				cli.printUsage()
-				os.Exit(1)
-			}
-			cli.addLoanContract(os.Args[3:])
-			break
-		default:
-			fmt.Println("Unsupported transaction type:", txType)
-			cli.printUsage()
-			os.Exit(1)
-		}
-		break
+		cli.addBlock(os.Args[2], os.Args[3:])
+	case "createidentity":
+		cli.createIdentity(os.Args[2:])
+	case "mine":
+		cli.mine()
+	case "startnode":
+		cli.startNode(os.Args[2:])
+	case "migrate":
+		cli.migrate()
+	case "verifychain":
+		cli.verifyChain()
+	case "history":
+		cli.history(os.Args[2:])
+	case "coverage":
+		cli.coverage(os.Args[2:])
+	case "loanschedule":
+		cli.loanSchedule(os.Args[2:])
+	case "inventory":
+		cli.inventory(os.Args[2:])
+	case "stolen":
+		cli.stolen()
+	case "recalls":
+		cli.recalls(os.Args[2:])
+	case "report":
+		cli.report(os.Args[2:])
 	case "printchain":
 		//println("CALLING PRINTCHAIN")
 		cli.printChain()
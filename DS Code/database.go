@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/boltdb/bolt"
 	"log"
+	"os"
+	"time"
 )
 
 const dbFile = "blockchain.db"
 const blocksBucket = "blocks"
+const mempoolBucket = "mempool"
 
 // Blockchain keeps a sequence of Blocks
 type Blockchain struct {
@@ -20,13 +26,18 @@ type BlockchainIterator struct {
 	db          *bolt.DB
 }
 
-// AddBlock saves provided data as a block in the blockchain
-func (bc *Blockchain) AddBlock(t []Transaction) {
+// errStaleTip is returned when another block was stored while ours was being mined
+var errStaleTip = errors.New("the chain tip moved while the block was being mined")
+
+// AddBlock mines the transactions into a block and saves it in the
+// blockchain. Mining stops early if ctx is cancelled.
+func (bc *Blockchain) AddBlock(ctx context.Context, t []Transaction, progress MiningProgress) (*Block, error) {
 	var lastHash []byte
 
 	err := bc.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
+		// Copied, as bolt's slices are only valid inside the transaction
+		lastHash = append([]byte{}, b.Get([]byte("l"))...)
 
 		return nil
 	})
@@ -35,10 +46,21 @@ func (bc *Blockchain) AddBlock(t []Transaction) {
 		log.Panic(err)
 	}
 
-	newBlock := NewBlock(t, lastHash)
+	window := bc.retargetWindow(lastHash)
+	parent := window[len(window)-1]
+	// A block may not be dated before its parent, even when a peer's clock is ahead of ours
+	timestamp := max(time.Now().Unix(), parent.Timestamp)
+	newBlock, err := NewBlock(ctx, t, lastHash, parent.Height+1, timestamp, nextTargetBits(window), progress)
+	if err != nil {
+		return nil, err
+	}
 
 	err = bc.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
+		if !bytes.Equal(b.Get([]byte("l")), lastHash) {
+			return errStaleTip
+		}
+
 		err := b.Put(newBlock.Hash, newBlock.Serialize())
 		if err != nil {
 			log.Panic(err)
@@ -49,10 +71,39 @@ func (bc *Blockchain) AddBlock(t []Transaction) {
 			log.Panic(err)
 		}
 
+		// Keep the VIN index in step with the chain in the same bolt transaction
+		if err := indexBlock(tx, newBlock); err != nil {
+			return err
+		}
+
 		bc.tip = newBlock.Hash
 
 		return nil
 	})
+	if err == errStaleTip {
+		return nil, err
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return newBlock, nil
+}
+
+// lastHash reads the tip from the database. Unlike bc.tip it is safe to call
+// while another goroutine may be storing a block.
+func (bc *Blockchain) lastHash() []byte {
+	var lastHash []byte
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		lastHash = append([]byte{}, tx.Bucket([]byte(blocksBucket)).Get([]byte("l"))...)
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return lastHash
 }
 
 func (bc *Blockchain) Iterator() *BlockchainIterator {
@@ -61,33 +112,153 @@ func (bc *Blockchain) Iterator() *BlockchainIterator {
 	return bci
 }
 
-// Next returns next block starting from the tip
+// Next returns next block starting from the tip. It panics if the block is
+// missing or cannot be decoded; BlocksFromGenesis reports such errors instead.
 func (i *BlockchainIterator) Next() *Block {
 	var block *Block
 
 	err := i.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		encodedBlock := b.Get(i.currentHash)
-		//println("INSIDE NEXT 1", encodedBlock)
-		block, _ = DeserializeBlock(encodedBlock)
-		//println("INSIDE NEXT 2", block)
+		if encodedBlock == nil {
+			return fmt.Errorf("block %x is missing from the database", i.currentHash)
+		}
+
+		var err error
+		block, err = DeserializeBlock(encodedBlock)
+		if err != nil {
+			return fmt.Errorf("block %x: %w", i.currentHash, err)
+		}
 		return nil
 	})
 
 	if err != nil {
 		log.Panic(err)
-
 	}
 
 	i.currentHash = block.PrevBlockHash
-	//println("INSIDE NEXT 3", block)
 
 	return block
 }
 
-// NewBlockchain creates a new Blockchain with genesis Block
+// GetBlock loads a single block by hash
+func (bc *Blockchain) GetBlock(hash []byte) (*Block, error) {
+	var block *Block
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		encodedBlock := tx.Bucket([]byte(blocksBucket)).Get(hash)
+		if encodedBlock == nil {
+			return fmt.Errorf("block %x not found", hash)
+		}
+
+		var err error
+		block, err = DeserializeBlock(encodedBlock)
+		return err
+	})
+
+	return block, err
+}
+
+// BlocksFromGenesis loads the whole chain ordered from genesis to tip. Unlike
+// the iterator it reports a missing or undecodable block instead of panicking.
+func (bc *Blockchain) BlocksFromGenesis() ([]*Block, error) {
+	var blocks []*Block
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		currentHash := bc.tip
+
+		for {
+			encodedBlock := b.Get(currentHash)
+			if encodedBlock == nil {
+				return fmt.Errorf("block %x is missing from the database", currentHash)
+			}
+
+			block, err := DeserializeBlock(encodedBlock)
+			if err != nil {
+				return fmt.Errorf("block %x: %w", currentHash, err)
+			}
+			blocks = append(blocks, block)
+
+			if len(block.PrevBlockHash) == 0 {
+				return nil
+			}
+			currentHash = block.PrevBlockHash
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+
+	return blocks, nil
+}
+
+// dbExists reports whether a blockchain database has been created here
+func dbExists() bool {
+	_, err := os.Stat(dbFile)
+	return err == nil
+}
+
+// CreateBlockchain creates a new blockchain whose genesis block names
+// authority as the key allowed to authorize manufacturers
+func CreateBlockchain(authority []byte) *Blockchain {
+	if dbExists() {
+		fmt.Println("Blockchain already exists.")
+		os.Exit(1)
+	}
+
+	genesis := NewGenesisBlock(authority)
+	db, err := bolt.Open(dbFile, 0600, nil)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(blocksBucket))
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put(genesis.Hash, genesis.Serialize()); err != nil {
+			return err
+		}
+		if err := b.Put([]byte("l"), genesis.Hash); err != nil {
+			return err
+		}
+
+		for _, name := range append([]string{mempoolBucket}, indexBuckets...) {
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		if err := putIndexVersion(tx); err != nil {
+			return err
+		}
+
+		// Records the authority; the genesis block touches no VIN
+		return indexBlock(tx, genesis)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return &Blockchain{genesis.Hash, db}
+}
+
+// NewBlockchain opens the existing blockchain, exiting if none has been created
 func NewBlockchain() *Blockchain {
+	if !dbExists() {
+		fmt.Println("No existing blockchain found. Create one with createblockchain first.")
+		os.Exit(1)
+	}
+
 	var tip []byte
+	var needsReindex bool
 	db, err := bolt.Open(dbFile, 0600, nil)
 	if err != nil {
 		log.Panic(err)
@@ -95,38 +266,44 @@ func NewBlockchain() *Blockchain {
 
 	err = db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-
 		if b == nil {
-			fmt.Println("No existing blockchain found. Creating a new one...")
-			genesis := NewGenesisBlock()
-
-			b, err := tx.CreateBucket([]byte(blocksBucket))
-			if err != nil {
-				log.Panic(err)
-			}
+			return errors.New("the database holds no blocks")
+		}
+		// Copied, as Reindex writes to the database before tip is read
+		tip = append([]byte{}, b.Get([]byte("l"))...)
 
-			err = b.Put(genesis.Hash, genesis.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
+		if _, err := tx.CreateBucketIfNotExists([]byte(mempoolBucket)); err != nil {
+			return err
+		}
 
-			err = b.Put([]byte("l"), genesis.Hash)
-			if err != nil {
-				log.Panic(err)
+		// Databases created before an index existed are indexed once on open
+		for _, name := range indexBuckets {
+			if tx.Bucket([]byte(name)) == nil {
+				if _, err := tx.CreateBucket([]byte(name)); err != nil {
+					return err
+				}
+				needsReindex = true
 			}
-			tip = genesis.Hash
-		} else {
-			tip = b.Get([]byte("l"))
+		}
+		if string(b.Get([]byte(indexVersionKey))) != indexVersion {
+			needsReindex = true
 		}
 
 		return nil
 	})
-
 	if err != nil {
 		log.Panic(err)
 	}
 
 	bc := Blockchain{tip, db}
+	if needsReindex {
+		bc.Reindex()
+	}
+
+	// Notices go to stderr so they never mix with JSON output
+	if bc.Iterator().Next().legacy {
+		fmt.Fprintln(os.Stderr, "This blockchain uses the legacy gob encoding; run 'migrate' to convert it.")
+	}
 
 	return &bc
 }
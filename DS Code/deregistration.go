@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("Deregistration", func() Transaction { return &Deregistration{} }, &CLISpec{
+		Usage: "-vin VIN -reason REASON -issuer ISSUER -date DATE",
+		Run:   (*CLI).addDeregistration,
+	})
+}
+
+// deregistrationReasons are the ways a vehicle can leave the road for good
+var deregistrationReasons = []string{"scrapped", "exported", "destroyed"}
+
+// Deregistration permanently retires a VIN. It is signed by the current
+// owner or a DMV, and every later transaction for the VIN is rejected.
+type Deregistration struct {
+	Nonce
+	VIN                string
+	Reason             string // One of deregistrationReasons
+	Issuer             []byte
+	DeregistrationDate int64
+	Signature          []byte // By Issuer
+}
+
+func (vr *Deregistration) ID() string {
+	return vr.VIN
+}
+
+// Liens must be released first; a stolen vehicle may be found destroyed or exported
+func (vr *Deregistration) SourceStates() []VehicleState {
+	return []VehicleState{Unregistered, Registered, Stolen}
+}
+
+func (vr *Deregistration) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeString(vr.Reason)
+	e.writeBytes(vr.Issuer)
+	e.writeInt64(vr.DeregistrationDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *Deregistration) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Reason = d.readString()
+	vr.Issuer = d.readBytes()
+	vr.DeregistrationDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *Deregistration) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *Deregistration) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Issuer, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *Deregistration) print_transaction() {
+	fmt.Println("Deregistration Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Reason: %s\n", vr.Reason)
+	fmt.Printf("Issuer: %x\n", vr.Issuer)
+	fmt.Printf("Deregistration Date: %s\n", time.Unix(vr.DeregistrationDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *Deregistration) jsonView() any {
+	return struct {
+		VIN                string   `json:"vin"`
+		Reason             string   `json:"reason"`
+		Issuer             HexBytes `json:"issuer"`
+		DeregistrationDate ISODate  `json:"deregistrationDate"`
+		Signature          HexBytes `json:"signature"`
+	}{vr.VIN, vr.Reason, vr.Issuer, ISODate(vr.DeregistrationDate), vr.Signature}
+}
+
+func (cli *CLI) addDeregistration(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("Deregistration", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	reason := cmd.String("reason", "", "Reason: scrapped, exported or destroyed")
+	issuer := cmd.String("issuer", "", "Local identity of the owner or DMV, who signs the deregistration")
+	date := cmd.String("date", "", "Deregistration date in YYYY-MM-DD format")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if !validDeregistrationReason(*reason) {
+		fmt.Println("Reason must be scrapped, exported or destroyed.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *issuer == "" {
+		fmt.Println("An issuer's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	deregistrationDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid deregistration date format. Use YYYY-MM-DD.")
+	}
+
+	issuerID := cli.identity(*issuer)
+
+	dr := &Deregistration{VIN: *vin, Reason: *reason, Issuer: issuerID.PublicKey, DeregistrationDate: deregistrationDate.Unix()}
+	dr.Sign(issuerID.PrivateKey)
+
+	// Ownership and state checks run against the chain and the pending pool
+	cli.submit(dr)
+
+	fmt.Println("Deregistration transaction added to the pending pool!")
+}
+
+func validDeregistrationReason(reason string) bool {
+	for _, r := range deregistrationReasons {
+		if r == reason {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Canonical encoding, format version 1
+//
+// Blocks and transactions are hashed and stored in a fixed binary layout so
+// any implementation can reproduce the hashes byte for byte. Primitives:
+//
+//	u8      one byte
+//	u32     4-byte big-endian unsigned integer
+//	i64     8-byte big-endian two's complement integer (all Go ints)
+//	bytes   u32 length followed by that many raw bytes
+//	string  bytes holding UTF-8 text
+//	bool    u8, 0 or 1
+//	list    u32 element count followed by the elements
+//
+// A transaction is
+//
+//	u8      format version (1 or 2)
+//	string  type name, e.g. "VehicleSale"
+//	i64     Nonce, in version 2 only
+//	...     the type's fields in struct declaration order
+//
+// and its hash (the Merkle leaf) is SHA-256 over exactly those bytes. Each
+// party signs the same encoding with every signature field left empty.
+// Transactions signed before nonces existed have none and keep version 1. Fields
+// added to a type after it first shipped come last and are written only when
+// set, so records encoded before they existed keep their bytes and hash.
+//
+// The proof-of-work header is
+//
+//	u8      format version (1)
+//	bytes   PrevBlockHash
+//	bytes   MerkleRoot
+//	i64     Timestamp
+//	i64     Height
+//	i64     TargetBits
+//	i64     Nonce
+//
+// and a stored block is
+//
+//	u8      format version (1)
+//	i64     Timestamp
+//	bytes   PrevBlockHash
+//	bytes   MerkleRoot
+//	i64     Height
+//	i64     TargetBits
+//	i64     Nonce
+//	bytes   Hash
+//	u32     transaction count
+//	bytes   each transaction's encoding
+//
+// Data that does not start with the version byte is treated as the legacy
+// encoding/gob format; the migrate command rewrites such databases.
+const (
+	encodingVersion      byte = 1
+	nonceEncodingVersion byte = 2 // Transactions carrying a Nonce
+)
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) writeByte(b byte) {
+	e.buf.WriteByte(b)
+}
+
+func (e *encoder) writeUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) writeInt64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) writeInt(v int) {
+	e.writeInt64(int64(v))
+}
+
+func (e *encoder) writeBytes(b []byte) {
+	e.writeUint32(uint32(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) writeString(s string) {
+	e.writeBytes([]byte(s))
+}
+
+func (e *encoder) writeBool(v bool) {
+	if v {
+		e.writeByte(1)
+	} else {
+		e.writeByte(0)
+	}
+}
+
+func (e *encoder) writeStrings(list []string) {
+	e.writeUint32(uint32(len(list)))
+	for _, s := range list {
+		e.writeString(s)
+	}
+}
+
+func (e *encoder) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// decoder reads the canonical encoding. The first error sticks and every
+// later read returns a zero value, so callers check err once at the end.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+var errTruncated = errors.New("unexpected end of encoded data")
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = errTruncated
+		return nil
+	}
+
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) readByte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) readUint32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) readInt64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) readInt() int {
+	return int(d.readInt64())
+}
+
+func (d *decoder) readBytes() []byte {
+	n := d.readUint32()
+	b := d.next(int(n))
+	if b == nil || n == 0 {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (d *decoder) readString() string {
+	return string(d.readBytes())
+}
+
+func (d *decoder) readBool() bool {
+	return d.readByte() != 0
+}
+
+// readCount reads a list's element count. Every element takes at least four
+// bytes, so a count the remaining data cannot hold is reported as truncation.
+func (d *decoder) readCount() int {
+	n := int(d.readUint32())
+	if d.err == nil && n > len(d.data)/4 {
+		d.err = errTruncated
+	}
+	if d.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) readStrings() []string {
+	var list []string
+	for n := d.readCount(); n > 0; n-- {
+		list = append(list, d.readString())
+	}
+	return list
+}
+
+// finish reports the first decoding error, or trailing bytes that no field consumed
+func (d *decoder) finish() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("%d unexpected trailing bytes", len(d.data))
+	}
+	return nil
+}
+
+// newTransactionEncoder starts a transaction's canonical encoding with its
+// version byte, type name and nonce
+func newTransactionEncoder(tx Transaction) *encoder {
+	e := &encoder{}
+	n, ok := tx.(nonced)
+	if !ok || *n.nonce() == 0 {
+		e.writeByte(encodingVersion)
+		e.writeString(transactionType(tx))
+		return e
+	}
+
+	e.writeByte(nonceEncodingVersion)
+	e.writeString(transactionType(tx))
+	e.writeInt64(int64(*n.nonce()))
+	return e
+}
+
+// newTransactionDecoder checks the version byte and type name at the start
+// of a transaction's encoding and returns a decoder positioned at its fields
+func newTransactionDecoder(data []byte, tx Transaction) (*decoder, error) {
+	d := &decoder{data: data}
+	version := d.readByte()
+	if d.err == nil && version != encodingVersion && version != nonceEncodingVersion {
+		return nil, fmt.Errorf("unsupported transaction encoding version %d", version)
+	}
+
+	if name := d.readString(); d.err == nil && name != transactionType(tx) {
+		return nil, fmt.Errorf("encoded transaction is a %s, not a %s", name, transactionType(tx))
+	}
+
+	if version == nonceEncodingVersion {
+		n, ok := tx.(nonced)
+		if !ok {
+			return nil, fmt.Errorf("a %s does not carry a nonce", transactionType(tx))
+		}
+		if *n.nonce() = Nonce(d.readInt64()); d.err == nil && *n.nonce() == 0 {
+			return nil, errors.New("a version 2 transaction must have a non-zero nonce")
+		}
+	}
+
+	return d, d.err
+}
+
+// isCanonical reports whether data uses a versioned encoding rather than legacy gob
+func isCanonical(data []byte) bool {
+	return len(data) > 0 && (data[0] == encodingVersion || data[0] == nonceEncodingVersion)
+}
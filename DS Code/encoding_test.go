@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fill sets every exported field of v to a distinct non-zero value, so a
+// field dropped or swapped by Serialize or Deserialize shows up as a mismatch
+func fill(v reflect.Value, next *int) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), next)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), next)
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), next)
+		}
+	case reflect.String:
+		*next++
+		v.SetString(fmt.Sprintf("field-%d", *next))
+	case reflect.Int, reflect.Int64:
+		// Includes the embedded Nonce, which moves the encoding to version 2
+		*next++
+		v.SetInt(int64(*next))
+	case reflect.Uint8:
+		*next++
+		v.SetUint(uint64(*next))
+	case reflect.Bool:
+		v.SetBool(true)
+	}
+}
+
+func TestTransactionRoundTrip(t *testing.T) {
+	for _, name := range registrationOrder {
+		t.Run(name, func(t *testing.T) {
+			for _, filled := range []bool{false, true} {
+				tx, _ := NewTransaction(name)
+				if filled {
+					next := 0
+					fill(reflect.ValueOf(tx).Elem(), &next)
+				}
+
+				data := tx.Serialize()
+				decoded, err := decodeTransaction(name, data)
+				if err != nil {
+					t.Fatalf("decoding %x: %v", data, err)
+				}
+				if !reflect.DeepEqual(decoded, tx) {
+					t.Fatalf("round trip changed the transaction\n got: %+v\nwant: %+v", decoded, tx)
+				}
+				if again := decoded.Serialize(); !bytes.Equal(again, data) {
+					t.Fatalf("re-encoding gave %x, want %x", again, data)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsOtherType(t *testing.T) {
+	data := (&VehicleSale{VIN: "1HGCM82633A004352"}).Serialize()
+	if _, err := decodeTransaction("VehicleRegistration", data); err == nil {
+		t.Fatal("a VehicleSale decoded as a VehicleRegistration")
+	}
+	if _, err := decodeTransaction("VehicleSale", append(data, 0)); err == nil {
+		t.Fatal("trailing bytes were accepted")
+	}
+}
+
+// Blocks written before the canonical encoding hold gob-encoded transactions
+// inside a gob-encoded block
+func TestLegacyBlockDecodes(t *testing.T) {
+	sale := &VehicleSale{VIN: "1HGCM82633A004352", Dealer: []byte{1}, Buyer: []byte{2}, SaleDate: 1700000000}
+
+	var txData bytes.Buffer
+	if err := gob.NewEncoder(&txData).Encode(sale); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := struct {
+		Timestamp         int64
+		Transaction_types []string
+		Transactions      [][]byte
+		PrevBlockHash     []byte
+		MerkleRoot        []byte
+		Height            int
+		TargetBits        int
+		Hash              []byte
+		Nonce             int
+	}{
+		Timestamp:         1700000000,
+		Transaction_types: []string{"VehicleSale"},
+		Transactions:      [][]byte{txData.Bytes()},
+		PrevBlockHash:     []byte{3},
+		Height:            4,
+		TargetBits:        initialTargetBits,
+		Hash:              []byte{5},
+		Nonce:             6,
+	}
+
+	var blockData bytes.Buffer
+	if err := gob.NewEncoder(&blockData).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := DeserializeBlock(blockData.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !block.legacy || block.Height != 4 || block.Nonce != 6 || len(block.Transactions) != 1 {
+		t.Fatalf("unexpected legacy block %+v", block)
+	}
+	if !reflect.DeepEqual(block.Transactions[0], sale) {
+		t.Fatalf("legacy transaction decoded as %+v, want %+v", block.Transactions[0], sale)
+	}
+
+	// Migrating rewrites the block canonically without changing its contents
+	migrated, err := DeserializeBlock(block.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.legacy || !reflect.DeepEqual(migrated.Transactions, block.Transactions) {
+		t.Fatalf("canonical copy of the legacy block differs: %+v", migrated)
+	}
+}
+
+// Identical payments signed separately must not share a hash, or the second
+// would be rejected as a replay of the first
+func TestNonceSeparatesIdenticalTransactions(t *testing.T) {
+	lender := NewIdentity("lender")
+	payments := []*LoanPayment{}
+	for i := 0; i < 2; i++ {
+		lp := &LoanPayment{VIN: "1HGCM82633A004352", Loan: []byte{1}, Lender: lender.PublicKey, Amount: 500, PaymentDate: 1700000000}
+		lp.Sign(lender.PrivateKey)
+		if !lp.Verify() {
+			t.Fatal("payment does not verify")
+		}
+		payments = append(payments, lp)
+	}
+
+	if bytes.Equal(TransactionHash(payments[0]), TransactionHash(payments[1])) {
+		t.Fatal("identical payments share a hash")
+	}
+
+	// Changing the nonce after signing invalidates the signature
+	payments[0].Nonce++
+	if payments[0].Verify() {
+		t.Fatal("signature does not cover the nonce")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"Take1_Autochain/vin"
+)
+
+// HistoryEntry is one transaction touching a VIN, with where it sits in the chain
+type HistoryEntry struct {
+	Type        string      `json:"type"`
+	BlockHash   HexBytes    `json:"blockHash"`
+	BlockHeight int         `json:"blockHeight"`
+	BlockTime   time.Time   `json:"blockTime"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// OwnershipPeriod is a stretch of time during which one party owned the vehicle
+type OwnershipPeriod struct {
+	Owner    HexBytes   `json:"owner"`
+	From     time.Time  `json:"from"`
+	To       *time.Time `json:"to,omitempty"` // nil for the current owner
+	Acquired string     `json:"acquired"`     // How ownership started: manufacture, registration or sale
+	Price    int        `json:"price,omitempty"`
+}
+
+// LienLink is one loan in a vehicle's chain of liens
+type LienLink struct {
+	Loan       HexBytes `json:"loan"`
+	Lender     HexBytes `json:"lender"`
+	Borrower   HexBytes `json:"borrower"`
+	Amount     int      `json:"amount"`
+	Paid       int      `json:"paid"`
+	Status     string   `json:"status"`
+	Refinances HexBytes `json:"refinances,omitempty"` // Loan paid off at the sale this one financed
+}
+
+// VehicleHistory returns every transaction recorded for vin, oldest first
+func (bc *Blockchain) VehicleHistory(vin string) ([]HistoryEntry, error) {
+	record := bc.FindVehicle(vin)
+	if record == nil {
+		return nil, errors.New("vehicle not found")
+	}
+
+	var entries []HistoryEntry
+	blocks := make(map[string]*Block)
+	for _, pos := range record.Positions {
+		block, ok := blocks[string(pos.BlockHash)]
+		if !ok {
+			var err error
+			if block, err = bc.GetBlock(pos.BlockHash); err != nil {
+				return nil, err
+			}
+			blocks[string(pos.BlockHash)] = block
+		}
+
+		tx := block.Transactions[pos.Index]
+		entries = append(entries, HistoryEntry{
+			Type:        transactionType(tx),
+			BlockHash:   block.Hash,
+			BlockHeight: block.Height,
+			BlockTime:   time.Unix(block.Timestamp, 0).UTC(),
+			Transaction: tx,
+		})
+	}
+
+	return entries, nil
+}
+
+// OwnershipTimeline derives the sequence of owners from a vehicle's history
+func OwnershipTimeline(entries []HistoryEntry) []OwnershipPeriod {
+	var periods []OwnershipPeriod
+
+	start := func(owner []byte, from int64, acquired string, price int) {
+		fromTime := time.Unix(from, 0).UTC()
+		if len(periods) > 0 {
+			periods[len(periods)-1].To = &fromTime
+		}
+		periods = append(periods, OwnershipPeriod{owner, fromTime, nil, acquired, price})
+	}
+
+	for _, entry := range entries {
+		switch tx := entry.Transaction.(type) {
+		case *ManufacturerIssue:
+			start(tx.Manufacturer, tx.IssueDate, "manufacture", 0)
+		case *VehicleRegistration:
+			// Only the first registration on a chain without an authority starts an ownership
+			if len(periods) > 0 {
+				continue
+			}
+			start(tx.Owner, tx.RegistrationDate, "registration", 0)
+		case *VehicleSale:
+			start(tx.Buyer, tx.SaleDate, "sale", tx.Price)
+		case *Deregistration:
+			// The last owner keeps the title, but the vehicle is gone
+			if len(periods) > 0 {
+				until := time.Unix(tx.DeregistrationDate, 0).UTC()
+				periods[len(periods)-1].To = &until
+			}
+		}
+	}
+
+	return periods
+}
+
+// LienChain lists every loan secured on the vehicle, oldest first. A loan
+// that took over another at a sale names it in Refinances.
+func LienChain(record *VehicleRecord) []LienLink {
+	var chain []LienLink
+	for _, lien := range record.Liens {
+		chain = append(chain, LienLink{
+			Loan:       lien.Loan,
+			Lender:     lien.Contract.Lender,
+			Borrower:   lien.Contract.Borrower,
+			Amount:     lien.Contract.LoanAmount,
+			Paid:       lien.Paid,
+			Status:     lien.Status(),
+			Refinances: lien.Refinances,
+		})
+	}
+
+	return chain
+}
+
+// decodeVIN returns what a record's VIN says about the vehicle, or nil for
+// chain-level records and VINs that do not decode
+func decodeVIN(tx Transaction) *vin.Info {
+	if isChainRecord(tx) {
+		return nil
+	}
+
+	info, err := vin.Decode(tx.ID())
+	if err != nil {
+		return nil
+	}
+
+	return info
+}
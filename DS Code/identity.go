@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+)
+
+const identityFile = "identities.dat"
+
+// Identity is an ed25519 key pair known by a local name. The public key is
+// what appears in the owner, dealer, buyer, borrower and lender fields.
+type Identity struct {
+	Name       string
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+// Identities stores the key pairs available on this machine
+type Identities struct {
+	Identities map[string]*Identity
+}
+
+// NewIdentity generates a fresh key pair
+func NewIdentity(name string) *Identity {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return &Identity{name, private, public}
+}
+
+// LoadIdentities reads the identity file, returning an empty set if it does not exist yet
+func LoadIdentities() (*Identities, error) {
+	ids := &Identities{make(map[string]*Identity)}
+
+	content, err := os.ReadFile(identityFile)
+	if os.IsNotExist(err) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(content)).Decode(ids); err != nil {
+		return nil, fmt.Errorf("failed to decode identities: %w", err)
+	}
+
+	return ids, nil
+}
+
+// SaveToFile writes the identities to the identity file
+func (ids *Identities) SaveToFile() {
+	var content bytes.Buffer
+
+	if err := gob.NewEncoder(&content).Encode(ids); err != nil {
+		log.Panic(err)
+	}
+
+	if err := os.WriteFile(identityFile, content.Bytes(), 0600); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Get returns the identity stored under name, or nil
+func (ids *Identities) Get(name string) *Identity {
+	return ids.Identities[name]
+}
+
+// ResolveParty turns a local identity name or a hex-encoded public key into a public key
+func (ids *Identities) ResolveParty(party string) ([]byte, error) {
+	if id := ids.Get(party); id != nil {
+		return id.PublicKey, nil
+	}
+
+	key, err := hex.DecodeString(party)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%q is neither a local identity nor a hex public key", party)
+	}
+
+	return key, nil
+}
+
+// signaturePayload returns the bytes a party signs for a transaction: its
+// canonical encoding, which starts with the type name. The transaction must
+// be passed with its signature fields cleared.
+func signaturePayload(tx Transaction) []byte {
+	return tx.Serialize()
+}
+
+// Nonce is embedded in every signed transaction type and encoded after the
+// type name. It tells apart transactions that are otherwise identical, such as
+// two equal payments on one day or a vehicle sold back on the same date, so
+// each has its own hash and only a replay of the same signed transaction is
+// rejected as a duplicate.
+type Nonce int64
+
+type nonced interface {
+	nonce() *Nonce
+}
+
+func (n *Nonce) nonce() *Nonce {
+	return n
+}
+
+// ensure picks a random nonce unless the transaction already has one, as it
+// does once any of its parties has signed. Every signature covers it.
+func (n *Nonce) ensure() {
+	for *n == 0 {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			log.Panic(err)
+		}
+		*n = Nonce(binary.BigEndian.Uint64(b[:]))
+	}
+}
+
+// verifySignature checks sig over payload against a raw public key
+func verifySignature(pubKey, payload, sig []byte) bool {
+	if len(pubKey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(pubKey), payload, sig)
+}
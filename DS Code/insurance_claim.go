@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("InsuranceClaim", func() Transaction { return &InsuranceClaim{} }, &CLISpec{
+		Usage: "-vin VIN -insurer INSURER -policy POLICY_ID -incident DATE -severity SEVERITY -payout PAYOUT",
+		Run:   (*CLI).addInsuranceClaim,
+	})
+}
+
+// claimSeverities are the damage grades an insurer can record, least severe first
+var claimSeverities = []string{"minor", "moderate", "severe", "total-loss"}
+
+// InsuranceClaim records an insurer settling a claim on one of its policies
+// for the vehicle. The incident must fall within the policy's coverage.
+type InsuranceClaim struct {
+	Nonce
+	VIN          string
+	Insurer      []byte
+	PolicyID     string
+	IncidentDate int64
+	Severity     string // One of claimSeverities
+	Payout       int
+	Signature    []byte // By Insurer
+}
+
+func (vr *InsuranceClaim) ID() string {
+	return vr.VIN
+}
+
+// Claims can be settled on a stolen vehicle, theft being a covered loss
+func (vr *InsuranceClaim) SourceStates() []VehicleState {
+	return []VehicleState{Unregistered, Registered, Liened, Stolen}
+}
+
+func (vr *InsuranceClaim) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Insurer)
+	e.writeString(vr.PolicyID)
+	e.writeInt64(vr.IncidentDate)
+	e.writeString(vr.Severity)
+	e.writeInt(vr.Payout)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *InsuranceClaim) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Insurer = d.readBytes()
+	vr.PolicyID = d.readString()
+	vr.IncidentDate = d.readInt64()
+	vr.Severity = d.readString()
+	vr.Payout = d.readInt()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *InsuranceClaim) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *InsuranceClaim) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Insurer, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *InsuranceClaim) print_transaction() {
+	fmt.Println("Insurance Claim Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Insurer: %x\n", vr.Insurer)
+	fmt.Printf("Policy ID: %s\n", vr.PolicyID)
+	fmt.Printf("Incident Date: %s\n", time.Unix(vr.IncidentDate, 0).Format("2006-01-02")) // Format Unix timestamp
+	fmt.Printf("Severity: %s\n", vr.Severity)
+	fmt.Printf("Payout: %d\n", vr.Payout)
+}
+
+func (vr *InsuranceClaim) jsonView() any {
+	return struct {
+		VIN          string   `json:"vin"`
+		Insurer      HexBytes `json:"insurer"`
+		PolicyID     string   `json:"policyId"`
+		IncidentDate ISODate  `json:"incidentDate"`
+		Severity     string   `json:"severity"`
+		Payout       int      `json:"payout"`
+		Signature    HexBytes `json:"signature"`
+	}{vr.VIN, vr.Insurer, vr.PolicyID, ISODate(vr.IncidentDate), vr.Severity, vr.Payout, vr.Signature}
+}
+
+func (cli *CLI) addInsuranceClaim(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("InsuranceClaim", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	insurer := cmd.String("insurer", "", "Insurer's local identity, who signs the claim")
+	policyID := cmd.String("policy", "", "Policy the claim is made on")
+	incident := cmd.String("incident", "", "Incident date in YYYY-MM-DD format")
+	severity := cmd.String("severity", "", "Damage severity: minor, moderate, severe or total-loss")
+	payout := cmd.Int("payout", 0, "Amount paid out")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *insurer == "" || *policyID == "" {
+		fmt.Println("Both the insurer's identifier and a policy number are required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if !validSeverity(*severity) {
+		fmt.Println("Severity must be minor, moderate, severe or total-loss.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *payout < 0 {
+		fmt.Println("Payout cannot be negative.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	incidentDate, err := time.Parse(layout, *incident)
+	if err != nil {
+		log.Panic("Invalid incident date format. Use YYYY-MM-DD.")
+	}
+
+	insurerID := cli.identity(*insurer)
+
+	ic := &InsuranceClaim{
+		VIN:          *vin,
+		Insurer:      insurerID.PublicKey,
+		PolicyID:     *policyID,
+		IncidentDate: incidentDate.Unix(),
+		Severity:     *severity,
+		Payout:       *payout,
+	}
+	ic.Sign(insurerID.PrivateKey)
+
+	// Coverage checks run against the chain and the pending pool
+	cli.submit(ic)
+
+	fmt.Println("Insurance claim transaction added to the pending pool!")
+}
+
+func validSeverity(severity string) bool {
+	for _, s := range claimSeverities {
+		if s == severity {
+			return true
+		}
+	}
+
+	return false
+}
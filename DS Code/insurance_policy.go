@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("InsurancePolicy", func() Transaction { return &InsurancePolicy{} }, &CLISpec{
+		Usage: "-vin VIN -insurer INSURER -policy POLICY_ID -start START -end END",
+		Run:   (*CLI).addInsurancePolicy,
+	})
+}
+
+// InsurancePolicy records that an insurer covers the vehicle from StartDate
+// up to EndDate. PolicyID is the insurer's own reference and is unique per VIN.
+type InsurancePolicy struct {
+	Nonce
+	VIN       string
+	Insurer   []byte
+	PolicyID  string
+	StartDate int64
+	EndDate   int64
+	Signature []byte // By Insurer
+}
+
+func (vr *InsurancePolicy) ID() string {
+	return vr.VIN
+}
+
+func (vr *InsurancePolicy) SourceStates() []VehicleState {
+	return activeStates
+}
+
+func (vr *InsurancePolicy) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Insurer)
+	e.writeString(vr.PolicyID)
+	e.writeInt64(vr.StartDate)
+	e.writeInt64(vr.EndDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *InsurancePolicy) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Insurer = d.readBytes()
+	vr.PolicyID = d.readString()
+	vr.StartDate = d.readInt64()
+	vr.EndDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *InsurancePolicy) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *InsurancePolicy) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Insurer, signaturePayload(&txCopy), vr.Signature)
+}
+
+// covers reports whether the policy is in force at time t
+func (vr *InsurancePolicy) covers(t int64) bool {
+	return vr.StartDate <= t && t < vr.EndDate
+}
+
+func (vr *InsurancePolicy) print_transaction() {
+	fmt.Println("Insurance Policy Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Insurer: %x\n", vr.Insurer)
+	fmt.Printf("Policy ID: %s\n", vr.PolicyID)
+	fmt.Printf("Start Date: %s\n", time.Unix(vr.StartDate, 0).Format("2006-01-02")) // Format Unix timestamp
+	fmt.Printf("End Date: %s\n", time.Unix(vr.EndDate, 0).Format("2006-01-02"))     // Format Unix timestamp
+}
+
+func (vr *InsurancePolicy) jsonView() any {
+	return struct {
+		VIN       string   `json:"vin"`
+		Insurer   HexBytes `json:"insurer"`
+		PolicyID  string   `json:"policyId"`
+		StartDate ISODate  `json:"startDate"`
+		EndDate   ISODate  `json:"endDate"`
+		Signature HexBytes `json:"signature"`
+	}{vr.VIN, vr.Insurer, vr.PolicyID, ISODate(vr.StartDate), ISODate(vr.EndDate), vr.Signature}
+}
+
+func (cli *CLI) addInsurancePolicy(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("InsurancePolicy", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	insurer := cmd.String("insurer", "", "Insurer's local identity, who signs the policy")
+	policyID := cmd.String("policy", "", "Insurer's policy number")
+	startDateStr := cmd.String("start", "", "First day of coverage in YYYY-MM-DD format")
+	endDateStr := cmd.String("end", "", "Day coverage ends in YYYY-MM-DD format")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *insurer == "" || *policyID == "" {
+		fmt.Println("Both the insurer's identifier and a policy number are required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	startDate, err := time.Parse(layout, *startDateStr)
+	if err != nil {
+		log.Panic("Invalid start date format. Use YYYY-MM-DD.")
+	}
+	endDate, err := time.Parse(layout, *endDateStr)
+	if err != nil {
+		log.Panic("Invalid end date format. Use YYYY-MM-DD.")
+	}
+
+	insurerID := cli.identity(*insurer)
+
+	ip := &InsurancePolicy{
+		VIN:       *vin,
+		Insurer:   insurerID.PublicKey,
+		PolicyID:  *policyID,
+		StartDate: startDate.Unix(),
+		EndDate:   endDate.Unix(),
+	}
+	ip.Sign(insurerID.PrivateKey)
+
+	cli.submit(ip)
+
+	fmt.Println("Insurance policy transaction added to the pending pool!")
+}
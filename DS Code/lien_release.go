@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("LienRelease", func() Transaction { return &LienRelease{} }, &CLISpec{
+		Usage: "-vin VIN -lender LENDER -date DATE [-loan HASH]",
+		Run:   (*CLI).addLienRelease,
+	})
+}
+
+// LienRelease is the lender giving up its claim on the vehicle, whether the
+// loan was repaid early, on time, or written off. Until it is recorded the
+// lien stays active, even past the contract's end date.
+type LienRelease struct {
+	Nonce
+	VIN         string
+	Loan        []byte // Hash of the LoanContract whose lien is released
+	Lender      []byte
+	ReleaseDate int64
+	Signature   []byte // By Lender
+}
+
+func (vr *LienRelease) ID() string {
+	return vr.VIN
+}
+
+// A lender can release its lien on a stolen vehicle, for instance after writing the loan off
+func (vr *LienRelease) SourceStates() []VehicleState {
+	return []VehicleState{Liened, Stolen}
+}
+
+func (vr *LienRelease) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Loan)
+	e.writeBytes(vr.Lender)
+	e.writeInt64(vr.ReleaseDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *LienRelease) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Loan = d.readBytes()
+	vr.Lender = d.readBytes()
+	vr.ReleaseDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *LienRelease) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *LienRelease) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Lender, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *LienRelease) print_transaction() {
+	fmt.Println("Lien Release Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Loan: %x\n", vr.Loan)
+	fmt.Printf("Lender: %x\n", vr.Lender)
+	fmt.Printf("Release Date: %s\n", time.Unix(vr.ReleaseDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *LienRelease) jsonView() any {
+	return struct {
+		VIN         string   `json:"vin"`
+		Loan        HexBytes `json:"loan"`
+		Lender      HexBytes `json:"lender"`
+		ReleaseDate ISODate  `json:"releaseDate"`
+		Signature   HexBytes `json:"signature"`
+	}{vr.VIN, vr.Loan, vr.Lender, ISODate(vr.ReleaseDate), vr.Signature}
+}
+
+func (cli *CLI) addLienRelease(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("LienRelease", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	lender := cmd.String("lender", "", "Lender's local identity, who signs the release")
+	date := cmd.String("date", "", "Release date in YYYY-MM-DD format")
+	loanHash := cmd.String("loan", "", "Hash of the loan contract, needed when the lender holds several liens on the vehicle")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *lender == "" {
+		fmt.Println("A lender's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	releaseDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid release date format. Use YYYY-MM-DD.")
+	}
+
+	lenderID := cli.identity(*lender)
+
+	lr := &LienRelease{
+		VIN:         *vin,
+		Loan:        cli.lenderLoan(*vin, lenderID.PublicKey, *loanHash),
+		Lender:      lenderID.PublicKey,
+		ReleaseDate: releaseDate.Unix(),
+	}
+	lr.Sign(lenderID.PrivateKey)
+
+	cli.submit(lr)
+
+	fmt.Println("Lien release transaction added to the pending pool!")
+}
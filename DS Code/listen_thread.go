@@ -1,11 +1,12 @@
 package main
 
 import (
-	"encoding/gob"
+	"context"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 )
 
 var upgrader = websocket.Upgrader{
@@ -17,10 +18,7 @@ var upgrader = websocket.Upgrader{
 // Using a sync.Map to safely handle concurrent access to the peers map.
 var peers sync.Map
 
-func handleConnections(w http.ResponseWriter, r *http.Request) {
-	bc := NewBlockchain()
-	defer bc.db.Close()
-
+func handleConnections(bc *Blockchain, w http.ResponseWriter, r *http.Request) {
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
@@ -52,15 +50,70 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 	log.Println("Disconnected:", ws.RemoteAddr())
 }
 
-func main() {
-	gob.Register(&VehicleRegistration{})
-	gob.Register(&VehicleSale{})
-	gob.Register(&LoanContract{})
-	gob.Register(&genesis{})
-	gob.Register(&Block{})
-	http.HandleFunc("/ws", handleConnections)
-	log.Println("WebSocket server started on :8080")
-	err := http.ListenAndServe(":8080", nil)
+// miner holds the cancel function of the block currently being mined so a
+// competing block from a peer can abort it
+var miner struct {
+	sync.Mutex
+	cancel context.CancelFunc
+}
+
+// abortMining stops the block currently being mined, if any
+func abortMining() {
+	miner.Lock()
+	defer miner.Unlock()
+
+	if miner.cancel != nil {
+		miner.cancel()
+	}
+}
+
+// mineLoop periodically drains the mempool and announces each mined block to peers
+func mineLoop(bc *Blockchain, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	progress := func(hashes uint64, hashRate float64) {
+		log.Printf("Mining: %d hashes, %.0f H/s", hashes, hashRate)
+	}
+
+	for range ticker.C {
+		ctx, cancel := context.WithCancel(context.Background())
+		miner.Lock()
+		miner.cancel = cancel
+		miner.Unlock()
+
+		block, rejected, err := bc.MinePendingTransactions(ctx, progress)
+
+		miner.Lock()
+		miner.cancel = nil
+		miner.Unlock()
+		cancel()
+
+		for _, err := range rejected {
+			log.Printf("Rejected pending transaction: %v", err)
+		}
+		if err != nil {
+			log.Printf("Mining abandoned: %v", err)
+			continue
+		}
+
+		if block != nil {
+			log.Printf("Mined block %x with %d transactions", block.Hash, len(block.Transactions))
+			broadcastMessage(CreateBlockCreationConfirmationMessage(block))
+		}
+	}
+}
+
+func startNode(bc *Blockchain, addr string, mineInterval time.Duration) {
+	if mineInterval > 0 {
+		go mineLoop(bc, mineInterval)
+	}
+
+	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		handleConnections(bc, w, r)
+	})
+	log.Println("WebSocket server started on", addr)
+	err := http.ListenAndServe(addr, nil)
 	if err != nil {
 		log.Fatal("ListenAndServe error:", err)
 	}
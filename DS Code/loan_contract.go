@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("LoanContract", func() Transaction { return &LoanContract{} }, &CLISpec{
+		Usage: "-vin VIN -borrower BORROWER -lender LENDER -amount AMOUNT -start START [-end END] [-apr PERCENT -frequency FREQUENCY -installments N]",
+		Run:   (*CLI).addLoanContract,
+	})
+}
+
+type LoanContract struct {
+	Nonce
+	VIN             string
+	Borrower        []byte
+	Lender          []byte
+	LoanAmount      int
+	StartDate       int64
+	EndDate         int64
+	Signature       []byte // By Borrower, who must be the current owner
+	LenderSignature []byte
+
+	// Repayment terms. Contracts from before they existed have none, so they
+	// are left out of the encoding when Installments is 0.
+	APR              int    // Annual percentage rate in basis points, e.g. 599 for 5.99%
+	PaymentFrequency string // monthly, biweekly or weekly
+	Installments     int    // Number of scheduled payments
+}
+
+func (vr *LoanContract) ID() string {
+	return vr.VIN
+}
+
+func (vr *LoanContract) SourceStates() []VehicleState {
+	return activeStates
+}
+
+func (vr *LoanContract) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Borrower)
+	e.writeBytes(vr.Lender)
+	e.writeInt(vr.LoanAmount)
+	e.writeInt64(vr.StartDate)
+	e.writeInt64(vr.EndDate)
+	e.writeBytes(vr.Signature)
+	e.writeBytes(vr.LenderSignature)
+	if vr.Installments > 0 {
+		e.writeInt(vr.APR)
+		e.writeString(vr.PaymentFrequency)
+		e.writeInt(vr.Installments)
+	}
+
+	return e.Bytes()
+}
+
+func (vr *LoanContract) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Borrower = d.readBytes()
+	vr.Lender = d.readBytes()
+	vr.LoanAmount = d.readInt()
+	vr.StartDate = d.readInt64()
+	vr.EndDate = d.readInt64()
+	vr.Signature = d.readBytes()
+	vr.LenderSignature = d.readBytes()
+	if len(d.data) > 0 {
+		vr.APR = d.readInt()
+		vr.PaymentFrequency = d.readString()
+		vr.Installments = d.readInt()
+	}
+
+	return d.finish()
+}
+
+// Sign adds the borrower's signature. Both parties sign the same payload,
+// which excludes both signatures, so they can sign in either order.
+func (vr *LoanContract) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	vr.Signature = ed25519.Sign(privKey, vr.signingData())
+}
+
+// CoSign adds the lender's signature
+func (vr *LoanContract) CoSign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	vr.LenderSignature = ed25519.Sign(privKey, vr.signingData())
+}
+
+func (vr *LoanContract) Verify() bool {
+	payload := vr.signingData()
+	return verifySignature(vr.Borrower, payload, vr.Signature) &&
+		verifySignature(vr.Lender, payload, vr.LenderSignature)
+}
+
+func (vr *LoanContract) signingData() []byte {
+	txCopy := *vr
+	txCopy.Signature = nil
+	txCopy.LenderSignature = nil
+	return signaturePayload(&txCopy)
+}
+
+func (vr *LoanContract) print_transaction() {
+	fmt.Println("Loan Contract Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Lender: %x\n", vr.Lender)
+	fmt.Printf("Borrower: %x\n", vr.Borrower)
+	fmt.Printf("Loan Amount: %d\n", vr.LoanAmount)
+	fmt.Printf("Start Date: %s\n", time.Unix(vr.StartDate, 0).Format("2006-01-02")) // Format Unix timestamp
+	fmt.Printf("End Date: %s\n", time.Unix(vr.EndDate, 0).Format("2006-01-02"))     // Format Unix timestamp
+	if vr.Installments > 0 {
+		fmt.Printf("APR: %s\n", formatAPR(vr.APR))
+		fmt.Printf("Installments: %d %s payments of %d\n", vr.Installments, vr.PaymentFrequency, vr.InstallmentAmount())
+	}
+}
+
+func (vr *LoanContract) jsonView() any {
+	return struct {
+		VIN              string   `json:"vin"`
+		Borrower         HexBytes `json:"borrower"`
+		Lender           HexBytes `json:"lender"`
+		LoanAmount       int      `json:"loanAmount"`
+		StartDate        ISODate  `json:"startDate"`
+		EndDate          ISODate  `json:"endDate"`
+		Signature        HexBytes `json:"signature"`
+		LenderSignature  HexBytes `json:"lenderSignature,omitempty"`
+		APR              int      `json:"apr,omitempty"`
+		PaymentFrequency string   `json:"paymentFrequency,omitempty"`
+		Installments     int      `json:"installments,omitempty"`
+	}{vr.VIN, vr.Borrower, vr.Lender, vr.LoanAmount, ISODate(vr.StartDate), ISODate(vr.EndDate), vr.Signature, vr.LenderSignature, vr.APR, vr.PaymentFrequency, vr.Installments}
+}
+
+func (cli *CLI) addLoanContract(args []string) {
+
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("LoanContract", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number (VIN)")
+	borrower := cmd.String("borrower", "", "Borrower's local identity, who signs the contract")
+	lender := cmd.String("lender", "", "Lender's local identity, who co-signs the contract")
+	loanAmount := cmd.Int("amount", 0, "The amount of the loan")
+	startDateStr := cmd.String("start", "", "The start date of the loan in YYYY-MM-DD format")
+	endDateStr := cmd.String("end", "", "The end date of the loan in YYYY-MM-DD format; defaults to the last installment's due date")
+	apr := cmd.Float64("apr", 0, "Annual percentage rate, e.g. 5.99")
+	frequency := cmd.String("frequency", "monthly", "Payment frequency: monthly, biweekly or weekly")
+	installments := cmd.Int("installments", 0, "Number of scheduled payments; 0 for a loan without a schedule")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	// Validate borrower and lender
+	if *borrower == "" || *lender == "" {
+		fmt.Println("Both borrower and lender identifiers are required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	// Validate loan amount
+	if *loanAmount <= 0 {
+		fmt.Println("Loan amount must be greater than 0.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	// Validate repayment terms
+	if *installments < 0 || *apr < 0 || (*installments == 0 && *apr > 0) {
+		fmt.Println("An APR needs a positive number of installments.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	if _, ok := paymentFrequencies[*frequency]; *installments > 0 && !ok {
+		fmt.Println("Payment frequency must be monthly, biweekly or weekly.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	startDate, err := time.Parse(layout, *startDateStr)
+	if err != nil {
+		log.Panic("Invalid start date format. Use YYYY-MM-DD.")
+	}
+
+	borrowerID := cli.identity(*borrower)
+	lenderID := cli.identity(*lender)
+
+	// Create the LoanContract transaction, signed by both parties
+	lc := &LoanContract{
+		VIN:        *vin,
+		Borrower:   borrowerID.PublicKey,
+		Lender:     lenderID.PublicKey,
+		LoanAmount: *loanAmount,
+		StartDate:  startDate.Unix(),
+	}
+	if *installments > 0 {
+		lc.APR = int(math.Round(*apr * 100))
+		lc.PaymentFrequency = *frequency
+		lc.Installments = *installments
+	}
+
+	if *endDateStr == "" && lc.Installments > 0 {
+		lc.EndDate = lc.dueDate(lc.Installments).Unix()
+	} else {
+		endDate, err := time.Parse(layout, *endDateStr)
+		if err != nil {
+			log.Panic("Invalid end date format. Use YYYY-MM-DD.")
+		}
+		lc.EndDate = endDate.Unix()
+	}
+	lc.Sign(borrowerID.PrivateKey)
+	lc.CoSign(lenderID.PrivateKey)
+
+	// Ownership checks run against the chain and the pending pool
+	cli.submit(lc)
+
+	fmt.Println("Loan contract transaction added to the pending pool!")
+}
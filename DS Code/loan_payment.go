@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("LoanPayment", func() Transaction { return &LoanPayment{} }, &CLISpec{
+		Usage: "-vin VIN -lender LENDER -amount AMOUNT -date DATE [-loan HASH]",
+		Run:   (*CLI).addLoanPayment,
+	})
+}
+
+// LoanPayment records a repayment towards a loan secured on the vehicle.
+// The lender signs it to acknowledge receiving the money.
+type LoanPayment struct {
+	Nonce
+	VIN         string
+	Loan        []byte // Hash of the LoanContract being repaid
+	Lender      []byte
+	Amount      int
+	PaymentDate int64
+	Signature   []byte // By Lender
+}
+
+func (vr *LoanPayment) ID() string {
+	return vr.VIN
+}
+
+// Repayment carries on while a stolen vehicle is missing
+func (vr *LoanPayment) SourceStates() []VehicleState {
+	return []VehicleState{Liened, Stolen}
+}
+
+func (vr *LoanPayment) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Loan)
+	e.writeBytes(vr.Lender)
+	e.writeInt(vr.Amount)
+	e.writeInt64(vr.PaymentDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *LoanPayment) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Loan = d.readBytes()
+	vr.Lender = d.readBytes()
+	vr.Amount = d.readInt()
+	vr.PaymentDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *LoanPayment) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *LoanPayment) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Lender, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *LoanPayment) print_transaction() {
+	fmt.Println("Loan Payment Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Loan: %x\n", vr.Loan)
+	fmt.Printf("Lender: %x\n", vr.Lender)
+	fmt.Printf("Amount: %d\n", vr.Amount)
+	fmt.Printf("Payment Date: %s\n", time.Unix(vr.PaymentDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *LoanPayment) jsonView() any {
+	return struct {
+		VIN         string   `json:"vin"`
+		Loan        HexBytes `json:"loan"`
+		Lender      HexBytes `json:"lender"`
+		Amount      int      `json:"amount"`
+		PaymentDate ISODate  `json:"paymentDate"`
+		Signature   HexBytes `json:"signature"`
+	}{vr.VIN, vr.Loan, vr.Lender, vr.Amount, ISODate(vr.PaymentDate), vr.Signature}
+}
+
+// lenderLoan picks the loan a payment or release refers to: the one given as
+// a hex hash, or else the only unreleased lien the lender holds on the vehicle
+func (cli *CLI) lenderLoan(vin string, lender []byte, loanHash string) []byte {
+	if loanHash != "" {
+		loan, err := hex.DecodeString(loanHash)
+		if err != nil {
+			fmt.Println("Error: the loan must be given as a hex transaction hash.")
+			os.Exit(1)
+		}
+		return loan
+	}
+
+	var held [][]byte
+	if record := cli.bc.FindVehicle(vin); record != nil {
+		for _, lien := range record.activeLiens() {
+			if bytes.Equal(lien.Contract.Lender, lender) {
+				held = append(held, lien.Loan)
+			}
+		}
+	}
+
+	switch len(held) {
+	case 0:
+		fmt.Println("Error: the lender holds no active lien on this vehicle.")
+		os.Exit(1)
+	case 1:
+		return held[0]
+	}
+
+	fmt.Println("Error: the lender holds several liens on this vehicle; choose one with -loan:")
+	for _, loan := range held {
+		fmt.Printf("  %x\n", loan)
+	}
+	os.Exit(1)
+	return nil
+}
+
+func (cli *CLI) addLoanPayment(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("LoanPayment", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	lender := cmd.String("lender", "", "Lender's local identity, who signs for the payment")
+	amount := cmd.Int("amount", 0, "Amount repaid")
+	date := cmd.String("date", "", "Payment date in YYYY-MM-DD format")
+	loanHash := cmd.String("loan", "", "Hash of the loan contract, needed when the lender holds several liens on the vehicle")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *lender == "" {
+		fmt.Println("A lender's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *amount <= 0 {
+		fmt.Println("Payment amount must be greater than 0.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	paymentDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid payment date format. Use YYYY-MM-DD.")
+	}
+
+	lenderID := cli.identity(*lender)
+
+	lp := &LoanPayment{
+		VIN:         *vin,
+		Loan:        cli.lenderLoan(*vin, lenderID.PublicKey, *loanHash),
+		Lender:      lenderID.PublicKey,
+		Amount:      *amount,
+		PaymentDate: paymentDate.Unix(),
+	}
+	lp.Sign(lenderID.PrivateKey)
+
+	// Balance checks run against the chain and the pending pool
+	cli.submit(lp)
+
+	fmt.Println("Loan payment transaction added to the pending pool!")
+}
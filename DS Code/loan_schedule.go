@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// paymentFrequencies maps a LoanContract's PaymentFrequency to the number of
+// payments a year
+var paymentFrequencies = map[string]int{
+	"monthly":  12,
+	"biweekly": 26,
+	"weekly":   52,
+}
+
+// maxInstallments caps a schedule at thirty years of weekly payments, which
+// keeps Schedule and Outstanding bounded
+const maxInstallments = 30 * 52
+
+// Installment is one row of a loan's amortization table
+type Installment struct {
+	Number    int       `json:"number"`
+	DueDate   time.Time `json:"dueDate"`
+	Payment   int       `json:"payment"`
+	Interest  int       `json:"interest"`
+	Principal int       `json:"principal"`
+	Balance   int       `json:"balance"` // Principal left after this payment
+}
+
+// validateTerms checks that the repayment terms are complete and consistent
+func (vr *LoanContract) validateTerms() error {
+	if vr.LoanAmount <= 0 {
+		return errors.New("the loan amount must be greater than 0")
+	}
+	if vr.EndDate < vr.StartDate {
+		return errors.New("the loan ends before it starts")
+	}
+	if vr.Installments == 0 {
+		if vr.APR != 0 || vr.PaymentFrequency != "" {
+			return errors.New("repayment terms need a number of installments")
+		}
+		return nil
+	}
+
+	if vr.Installments < 0 || vr.APR < 0 {
+		return errors.New("installments and APR cannot be negative")
+	}
+	if vr.Installments > maxInstallments {
+		return fmt.Errorf("a loan can have at most %d installments", maxInstallments)
+	}
+	if _, ok := paymentFrequencies[vr.PaymentFrequency]; !ok {
+		return fmt.Errorf("unknown payment frequency %q", vr.PaymentFrequency)
+	}
+
+	return nil
+}
+
+// periodRate returns the interest rate applied per payment period; 0 for a
+// loan without a schedule, which carries no interest
+func (vr *LoanContract) periodRate() float64 {
+	if vr.Installments <= 0 {
+		return 0
+	}
+
+	return float64(vr.APR) / 10000 / float64(paymentFrequencies[vr.PaymentFrequency])
+}
+
+// periodInterest returns the interest charged for one period on balance.
+// Schedule and Outstanding both use it so the two always agree.
+func (vr *LoanContract) periodInterest(balance int) int {
+	return int(math.Round(float64(balance) * vr.periodRate()))
+}
+
+// dueDate returns when installment n (counting from 1) falls due
+func (vr *LoanContract) dueDate(n int) time.Time {
+	start := time.Unix(vr.StartDate, 0).UTC()
+
+	switch vr.PaymentFrequency {
+	case "biweekly":
+		return start.AddDate(0, 0, 14*n)
+	case "weekly":
+		return start.AddDate(0, 0, 7*n)
+	default:
+		return start.AddDate(0, n, 0)
+	}
+}
+
+// InstallmentAmount returns the level payment that repays the loan over its
+// installments, rounded to the nearest unit; 0 for a loan without a schedule
+func (vr *LoanContract) InstallmentAmount() int {
+	if vr.Installments <= 0 {
+		return 0
+	}
+
+	n := float64(vr.Installments)
+	r := vr.periodRate()
+	if r == 0 {
+		return int(math.Ceil(float64(vr.LoanAmount) / n))
+	}
+
+	return int(math.Round(float64(vr.LoanAmount) * r / (1 - math.Pow(1+r, -n))))
+}
+
+// Schedule returns the amortization table. Interest for each period is
+// charged on the balance left after the previous payment, and the last
+// payment is adjusted to clear whatever rounding left over.
+func (vr *LoanContract) Schedule() []Installment {
+	var table []Installment
+	payment := vr.InstallmentAmount()
+	balance := vr.LoanAmount
+
+	for n := 1; n <= vr.Installments && balance > 0; n++ {
+		interest := vr.periodInterest(balance)
+		principal := min(payment-interest, balance)
+		if n == vr.Installments {
+			principal = balance
+		}
+		balance -= principal
+
+		table = append(table, Installment{n, vr.dueDate(n), interest + principal, interest, principal, balance})
+	}
+
+	return table
+}
+
+// Outstanding returns what is owed on the loan at the given time: principal
+// not yet repaid plus unpaid interest. As in Schedule, interest is charged at
+// each due date up to then on the principal owed over the period, so paying
+// the schedule on its due dates clears the loan exactly. Each recorded
+// payment first settles the interest charged before its date.
+func (l *Lien) Outstanding(at int64) int {
+	lc := &l.Contract
+	balance := lc.LoanAmount
+	accrued := 0
+	period := 1 // Next period whose interest has not been charged
+
+	// No interest is charged after the last installment falls due
+	accrue := func(until int64) {
+		for ; period <= lc.Installments && lc.dueDate(period).Unix() <= until; period++ {
+			accrued += lc.periodInterest(balance)
+		}
+	}
+
+	for _, p := range l.Payments {
+		accrue(p.PaymentDate)
+		interest := min(p.Amount, accrued)
+		accrued -= interest
+		balance -= p.Amount - interest
+	}
+	accrue(at)
+
+	return balance + accrued
+}
+
+// formatAPR renders an APR held in basis points as a percentage
+func formatAPR(bp int) string {
+	return fmt.Sprintf("%d.%02d%%", bp/100, bp%100)
+}
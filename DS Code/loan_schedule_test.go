@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Paying every installment of the printed schedule on its due date must be
+// accepted and leave nothing owed
+func TestPayingScheduleClearsLoan(t *testing.T) {
+	start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).Unix()
+	tests := []struct {
+		name         string
+		amount       int
+		apr          int
+		frequency    string
+		installments int
+	}{
+		{"monthly at 6%", 10000, 600, "monthly", 12},
+		{"weekly at 6%", 10000, 600, "weekly", 52},
+		{"biweekly at 6%", 10000, 600, "biweekly", 26},
+		{"monthly at 19.99%", 10000, 1999, "monthly", 12},
+		{"weekly at 19.99%", 25000, 1999, "weekly", 156},
+		{"monthly at 0%", 10000, 0, "monthly", 7},
+		{"monthly at 5.99% over six years", 38750, 599, "monthly", 72},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lien := &Lien{Contract: LoanContract{
+				LoanAmount:       tt.amount,
+				StartDate:        start,
+				APR:              tt.apr,
+				PaymentFrequency: tt.frequency,
+				Installments:     tt.installments,
+			}}
+
+			schedule := lien.Contract.Schedule()
+			if len(schedule) != tt.installments {
+				t.Fatalf("schedule has %d installments, want %d", len(schedule), tt.installments)
+			}
+
+			for _, row := range schedule {
+				due := row.DueDate.Unix()
+				if owed := lien.Outstanding(due); row.Payment > owed {
+					t.Fatalf("installment %d of %d exceeds the %d outstanding", row.Number, row.Payment, owed)
+				}
+				lien.Payments = append(lien.Payments, LoanPayment{Amount: row.Payment, PaymentDate: due})
+
+				if owed := lien.Outstanding(due); owed != row.Balance {
+					t.Fatalf("after installment %d, %d is outstanding but the schedule shows %d", row.Number, owed, row.Balance)
+				}
+			}
+
+			last := schedule[len(schedule)-1].DueDate
+			if owed := lien.Outstanding(last.AddDate(1, 0, 0).Unix()); owed != 0 {
+				t.Fatalf("%d still outstanding after paying the schedule", owed)
+			}
+		})
+	}
+}
+
+// Interest stops at the last installment, so no date, however far off, keeps
+// it accruing
+func TestOutstandingStopsAtScheduleEnd(t *testing.T) {
+	lien := &Lien{Contract: LoanContract{
+		LoanAmount:       10000,
+		StartDate:        time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).Unix(),
+		APR:              600,
+		PaymentFrequency: "monthly",
+		Installments:     12,
+	}}
+
+	end := lien.Contract.dueDate(12).Unix()
+	atEnd := lien.Outstanding(end)
+	if atEnd <= lien.Contract.LoanAmount {
+		t.Fatalf("%d outstanding at the end, want interest on top of %d", atEnd, lien.Contract.LoanAmount)
+	}
+	if owed := lien.Outstanding(math.MaxInt64); owed != atEnd {
+		t.Fatalf("%d outstanding at the end of time, want %d", owed, atEnd)
+	}
+
+	lien.Contract.EndDate = end
+	if err := lien.Contract.validateTerms(); err != nil {
+		t.Fatal(err)
+	}
+	lien.Contract.Installments = maxInstallments + 1
+	if err := lien.Contract.validateTerms(); err == nil {
+		t.Fatal("a schedule over the installment cap was accepted")
+	}
+}
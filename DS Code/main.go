@@ -6,14 +6,8 @@ import (
 
 func main() {
 
-	gob.Register(&VehicleRegistration{})
-	gob.Register(&VehicleSale{})
-	gob.Register(&LoanContract{})
-	gob.Register(&genesis{})
 	gob.Register(&Block{})
-	bc := NewBlockchain()
-	defer bc.db.Close()
 
-	cli := CLI{bc}
+	cli := CLI{}
 	cli.Run()
 }
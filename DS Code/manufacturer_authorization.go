@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"Take1_Autochain/vin"
+)
+
+func init() {
+	RegisterTransactionType("ManufacturerAuthorization", func() Transaction { return &ManufacturerAuthorization{} }, &CLISpec{
+		Usage: "-wmi WMI -manufacturer MANUFACTURER -name NAME -authority AUTHORITY",
+		Run:   (*CLI).addManufacturerAuthorization,
+	})
+}
+
+// ManufacturerAuthorization adds a manufacturer to the chain's authorized
+// list, allowing it to issue VINs that start with WMI. Only the authority
+// named in the genesis block may sign one.
+type ManufacturerAuthorization struct {
+	Nonce
+	WMI          string
+	Manufacturer []byte
+	Name         string
+	Authority    []byte
+	Signature    []byte // By Authority
+}
+
+func (vr *ManufacturerAuthorization) ID() string {
+	return vr.WMI
+}
+
+func (vr *ManufacturerAuthorization) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.WMI)
+	e.writeBytes(vr.Manufacturer)
+	e.writeString(vr.Name)
+	e.writeBytes(vr.Authority)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *ManufacturerAuthorization) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.WMI = d.readString()
+	vr.Manufacturer = d.readBytes()
+	vr.Name = d.readString()
+	vr.Authority = d.readBytes()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *ManufacturerAuthorization) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *ManufacturerAuthorization) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Authority, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *ManufacturerAuthorization) print_transaction() {
+	fmt.Println("Manufacturer Authorization Transaction")
+	fmt.Printf("WMI: %s\n", vr.WMI)
+	fmt.Printf("Manufacturer: %s (%x)\n", vr.Name, vr.Manufacturer)
+	fmt.Printf("Authority: %x\n", vr.Authority)
+}
+
+func (vr *ManufacturerAuthorization) jsonView() any {
+	return struct {
+		WMI          string   `json:"wmi"`
+		Manufacturer HexBytes `json:"manufacturer"`
+		Name         string   `json:"name"`
+		Authority    HexBytes `json:"authority"`
+		Signature    HexBytes `json:"signature"`
+	}{vr.WMI, vr.Manufacturer, vr.Name, vr.Authority, vr.Signature}
+}
+
+func (cli *CLI) addManufacturerAuthorization(args []string) {
+	cmd := flag.NewFlagSet("ManufacturerAuthorization", flag.ExitOnError)
+	wmi := cmd.String("wmi", "", "World Manufacturer Identifier, the first three characters of its VINs")
+	manufacturer := cmd.String("manufacturer", "", "Manufacturer's identity or public key")
+	name := cmd.String("name", "", "Manufacturer's name")
+	authority := cmd.String("authority", "", "Chain authority's local identity, who signs the authorization")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err := vin.ValidateWMI(*wmi); err != nil {
+		fmt.Println("Error:", err)
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *manufacturer == "" || *name == "" {
+		fmt.Println("The manufacturer's identifier and name are required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *authority == "" {
+		fmt.Println("The chain authority's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	authorityID := cli.identity(*authority)
+
+	ma := &ManufacturerAuthorization{
+		WMI:          *wmi,
+		Manufacturer: cli.party(*manufacturer),
+		Name:         *name,
+		Authority:    authorityID.PublicKey,
+	}
+	ma.Sign(authorityID.PrivateKey)
+
+	cli.submit(ma)
+
+	fmt.Println("Manufacturer authorization transaction added to the pending pool!")
+}
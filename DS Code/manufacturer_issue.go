@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("ManufacturerIssue", func() Transaction { return &ManufacturerIssue{} }, &CLISpec{
+		Usage: "-vin VIN -manufacturer MANUFACTURER -date DATE",
+		Run:   (*CLI).addManufacturerIssue,
+	})
+}
+
+// ManufacturerIssue brings a VIN into existence. It must be the first record
+// for the VIN and be signed by the manufacturer authorized for the VIN's WMI,
+// who becomes the vehicle's first owner.
+type ManufacturerIssue struct {
+	Nonce
+	VIN          string
+	Manufacturer []byte
+	IssueDate    int64
+	Signature    []byte // By Manufacturer
+}
+
+func (vr *ManufacturerIssue) ID() string {
+	return vr.VIN
+}
+
+func (vr *ManufacturerIssue) SourceStates() []VehicleState {
+	return []VehicleState{Unissued}
+}
+
+func (vr *ManufacturerIssue) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Manufacturer)
+	e.writeInt64(vr.IssueDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *ManufacturerIssue) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Manufacturer = d.readBytes()
+	vr.IssueDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *ManufacturerIssue) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *ManufacturerIssue) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Manufacturer, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *ManufacturerIssue) print_transaction() {
+	fmt.Println("Manufacturer Issue Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Manufacturer: %x\n", vr.Manufacturer)
+	fmt.Printf("Issue Date: %s\n", time.Unix(vr.IssueDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *ManufacturerIssue) jsonView() any {
+	return struct {
+		VIN          string   `json:"vin"`
+		Manufacturer HexBytes `json:"manufacturer"`
+		IssueDate    ISODate  `json:"issueDate"`
+		Signature    HexBytes `json:"signature"`
+	}{vr.VIN, vr.Manufacturer, ISODate(vr.IssueDate), vr.Signature}
+}
+
+func (cli *CLI) addManufacturerIssue(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("ManufacturerIssue", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	manufacturer := cmd.String("manufacturer", "", "Manufacturer's local identity, who signs the issue")
+	date := cmd.String("date", "", "Issue date in YYYY-MM-DD format")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *manufacturer == "" {
+		fmt.Println("A manufacturer's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	issueDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid issue date format. Use YYYY-MM-DD.")
+	}
+
+	manufacturerID := cli.identity(*manufacturer)
+
+	mi := &ManufacturerIssue{VIN: *vin, Manufacturer: manufacturerID.PublicKey, IssueDate: issueDate.Unix()}
+	mi.Sign(manufacturerID.PrivateKey)
+
+	// Queue the transaction for the next mined block
+	cli.submit(mi)
+
+	fmt.Println("Manufacturer issue transaction added to the pending pool!")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+// pendingTransaction is how a transaction waits in the mempool bucket
+type pendingTransaction struct {
+	Type string
+	Data []byte
+}
+
+// AddToMempool queues a transaction to be packed into the next mined block.
+// Keys come from the bucket sequence so the pool drains in arrival order.
+func (bc *Blockchain) AddToMempool(t Transaction) {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	if err := encoder.Encode(pendingTransaction{transactionType(t), t.Serialize()}); err != nil {
+		log.Panic(err)
+	}
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(mempoolBucket))
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return b.Put(sequenceKey(seq), result.Bytes())
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// sequenceKey encodes a bucket sequence number so keys sort in arrival order
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// PendingTransactions returns the queued transactions in arrival order
+func (bc *Blockchain) PendingTransactions() []Transaction {
+	_, txs := bc.pendingTransactions()
+	return txs
+}
+
+func (bc *Blockchain) pendingTransactions() ([][]byte, []Transaction) {
+	var keys [][]byte
+	var txs []Transaction
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(mempoolBucket))
+
+		return b.ForEach(func(k, v []byte) error {
+			var pending pendingTransaction
+			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&pending); err != nil {
+				return fmt.Errorf("failed to decode pending transaction: %w", err)
+			}
+
+			t, err := decodeTransaction(pending.Type, pending.Data)
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, append([]byte{}, k...))
+			txs = append(txs, t)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return keys, txs
+}
+
+func (bc *Blockchain) removeFromMempool(keys [][]byte) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(mempoolBucket))
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// MinePendingTransactions drains the mempool into a single block. Every
+// transaction is re-checked in order against the chain and the ones before
+// it; those that fail are dropped from the pool and reported. The returned
+// block is nil when nothing valid was pending. If mining is cancelled or
+// loses to another block, the error is returned and the pool is left as is.
+func (bc *Blockchain) MinePendingTransactions(ctx context.Context, progress MiningProgress) (*Block, []error, error) {
+	keys, txs := bc.pendingTransactions()
+	view := newLedgerView(bc)
+
+	var valid []Transaction
+	var rejected []error
+	for _, t := range txs {
+		if err := view.check(t); err != nil {
+			rejected = append(rejected, fmt.Errorf("%s %s: %w", transactionType(t), t.ID(), err))
+			continue
+		}
+
+		view.apply(t)
+		valid = append(valid, t)
+	}
+
+	var block *Block
+	if len(valid) > 0 {
+		var err error
+		if block, err = bc.AddBlock(ctx, valid, progress); err != nil {
+			return nil, rejected, err
+		}
+	}
+	bc.removeFromMempool(keys)
+
+	return block, rejected, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// MerkleTree represents a Merkle tree built over transaction hashes
+type MerkleTree struct {
+	RootNode *MerkleNode
+}
+
+// MerkleNode represents a Merkle tree node
+type MerkleNode struct {
+	Left  *MerkleNode
+	Right *MerkleNode
+	Data  []byte
+}
+
+// MerkleProofStep is one sibling hash on the path from a leaf to the root.
+// Left reports whether the sibling sits on the left of the running hash.
+type MerkleProofStep struct {
+	Hash []byte
+	Left bool
+}
+
+// NewMerkleTree creates a new Merkle tree from a sequence of leaf hashes
+func NewMerkleTree(leaves [][]byte) *MerkleTree {
+	if len(leaves) == 0 {
+		return &MerkleTree{NewMerkleNode(nil, nil, nil)}
+	}
+
+	var level []*MerkleNode
+	for _, leaf := range leaves {
+		level = append(level, NewMerkleNode(nil, nil, leaf))
+	}
+
+	for len(level) > 1 {
+		// Duplicate the last node on odd levels, as Bitcoin does. This gives
+		// [a b c] and [a b c c] the same root, so blocks must not repeat a
+		// transaction; see hasDuplicateLeaves.
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		var next []*MerkleNode
+		for j := 0; j < len(level); j += 2 {
+			next = append(next, NewMerkleNode(level[j], level[j+1], nil))
+		}
+		level = next
+	}
+
+	return &MerkleTree{level[0]}
+}
+
+// hasDuplicateLeaves reports whether any leaf hash appears more than once
+func hasDuplicateLeaves(leaves [][]byte) bool {
+	seen := make(map[string]bool, len(leaves))
+	for _, leaf := range leaves {
+		if seen[string(leaf)] {
+			return true
+		}
+		seen[string(leaf)] = true
+	}
+
+	return false
+}
+
+// NewMerkleNode creates a new Merkle tree node. Leaves keep the hash they are
+// given; inner nodes hash the concatenation of their children.
+func NewMerkleNode(left, right *MerkleNode, data []byte) *MerkleNode {
+	node := MerkleNode{}
+
+	if left == nil && right == nil {
+		node.Data = data
+	} else {
+		prevHashes := append(append([]byte{}, left.Data...), right.Data...)
+		hash := sha256.Sum256(prevHashes)
+		node.Data = hash[:]
+	}
+
+	node.Left = left
+	node.Right = right
+
+	return &node
+}
+
+// NewMerkleProof returns the sibling hashes needed to recompute the root from
+// the leaf at index
+func NewMerkleProof(leaves [][]byte, index int) []MerkleProofStep {
+	var proof []MerkleProofStep
+	level := append([][]byte{}, leaves...)
+
+	for len(level) > 1 {
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		if index%2 == 0 {
+			proof = append(proof, MerkleProofStep{level[index+1], false})
+		} else {
+			proof = append(proof, MerkleProofStep{level[index-1], true})
+		}
+
+		var next [][]byte
+		for j := 0; j < len(level); j += 2 {
+			hash := sha256.Sum256(append(append([]byte{}, level[j]...), level[j+1]...))
+			next = append(next, hash[:])
+		}
+		level = next
+		index /= 2
+	}
+
+	return proof
+}
+
+// VerifyMerkleProof checks that leaf is committed to by root through proof
+func VerifyMerkleProof(root, leaf []byte, proof []MerkleProofStep) bool {
+	hash := leaf
+	for _, step := range proof {
+		var sum [32]byte
+		if step.Left {
+			sum = sha256.Sum256(append(append([]byte{}, step.Hash...), hash...))
+		} else {
+			sum = sha256.Sum256(append(append([]byte{}, hash...), step.Hash...))
+		}
+		hash = sum[:]
+	}
+
+	return bytes.Equal(hash, root)
+}
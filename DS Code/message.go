@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -24,7 +25,17 @@ func handleMessage(msg Message, ws *websocket.Conn, bc *Blockchain) {
 	switch msg.Type {
 	case MsgNewBlock:
 		// Handle incoming new block message
-		handleNewBlockMessage(msg.Content)
+		data, err := base64.StdEncoding.DecodeString(msg.Content)
+		if err != nil {
+			log.Printf("Error decoding base64 content: %v", err)
+			return
+		}
+		block, err := DeserializeBlock(data)
+		if err != nil {
+			log.Printf("Failed to deserialize block: %v", err)
+			return
+		}
+		handleNewBlockMessage(block)
 	case MsgConsensusResult:
 		handleConsensusResult(msg, ws)
 	case MsgBlockCreationConfirmation:
@@ -109,8 +120,22 @@ func handleBlockCreationConfirmation(bc *Blockchain, content string, ws *websock
 		return
 	}
 
+	if err := bc.ValidateBlock(block); err != nil {
+		log.Printf("Rejected block %x: %v", block.Hash, err)
+		return
+	}
+
+	// A competing block won; drop the one we are working on so its pending
+	// transactions are re-checked against the new tip
+	abortMining()
+
 	err = bc.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
+		// Our own miner may have stored a block since the block was validated
+		if !bytes.Equal(b.Get([]byte("l")), block.PrevBlockHash) {
+			return errStaleTip
+		}
+
 		if err := b.Put(block.Hash, block.Serialize()); err != nil {
 			return err
 		}
@@ -119,9 +144,17 @@ func handleBlockCreationConfirmation(bc *Blockchain, content string, ws *websock
 			return err
 		}
 
+		if err := indexBlock(tx, block); err != nil {
+			return err
+		}
+
 		bc.tip = block.Hash
 		return nil
 	})
+	if err == errStaleTip {
+		log.Printf("Rejected block %x: %v", block.Hash, err)
+		return
+	}
 	if err != nil {
 		log.Panic("Failed to update blockchain database: ", err)
 	}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"github.com/boltdb/bolt"
+)
+
+// Migrate rewrites a database stored in the legacy gob format into the
+// canonical encoding. Block hashes depend on the encoding, so every block is
+// re-mined in order with its original timestamp and transactions, the
+// difficulty the retarget rule gives at its height, and a PrevBlockHash
+// pointing at the re-mined parent. Transaction signatures cover the
+// canonical encoding of the transaction alone and stay valid. The indexes and pending pool are rewritten too.
+// It returns the number of blocks migrated, 0 if nothing was legacy.
+func (bc *Blockchain) Migrate(ctx context.Context, progress MiningProgress) (int, error) {
+	blocks, err := bc.BlocksFromGenesis()
+	if err != nil {
+		return 0, err
+	}
+
+	needed := false
+	for _, block := range blocks {
+		needed = needed || block.legacy
+	}
+	if !needed {
+		return 0, nil
+	}
+
+	var migrated []*Block
+	var prevHash []byte
+	for height, old := range blocks {
+		block := &Block{
+			Timestamp:     old.Timestamp,
+			Transactions:  old.Transactions,
+			PrevBlockHash: prevHash,
+			Height:        height,
+			TargetBits:    nextTargetBits(migrated[max(0, height-retargetInterval):]),
+		}
+		if err := block.mine(ctx, progress); err != nil {
+			return 0, err
+		}
+
+		migrated = append(migrated, block)
+		prevHash = block.Hash
+	}
+
+	_, pending := bc.pendingTransactions()
+
+	err = bc.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range append([]string{blocksBucket, mempoolBucket}, indexBuckets...) {
+			if err := tx.DeleteBucket([]byte(name)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		for _, block := range migrated {
+			if err := b.Put(block.Hash, block.Serialize()); err != nil {
+				return err
+			}
+			if err := indexBlock(tx, block); err != nil {
+				return err
+			}
+		}
+		if err := b.Put([]byte("l"), prevHash); err != nil {
+			return err
+		}
+		if err := putIndexVersion(tx); err != nil {
+			return err
+		}
+
+		// Re-queue pending transactions in their canonical encoding, keeping their order
+		pool := tx.Bucket([]byte(mempoolBucket))
+		for _, t := range pending {
+			var result bytes.Buffer
+			if err := gob.NewEncoder(&result).Encode(pendingTransaction{transactionType(t), t.Serialize()}); err != nil {
+				return err
+			}
+			seq, err := pool.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := pool.Put(sequenceKey(seq), result.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	bc.tip = prevHash
+	return len(migrated), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"Take1_Autochain/vin"
+)
+
+// Output formats accepted by the global -format option. ndjson streams
+// printchain one block per line and prints other documents on a single line.
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// TransactionJSON is a transaction as it appears in JSON output
+type TransactionJSON struct {
+	Type        string    `json:"type"`
+	Hash        HexBytes  `json:"hash"`
+	VINDetails  *vin.Info `json:"vinDetails,omitempty"`
+	Transaction any       `json:"transaction"`
+}
+
+func newTransactionJSON(tx Transaction) TransactionJSON {
+	return TransactionJSON{transactionType(tx), TransactionHash(tx), decodeVIN(tx), tx.jsonView()}
+}
+
+// BlockJSON is a block as it appears in JSON output
+type BlockJSON struct {
+	Hash          HexBytes          `json:"hash"`
+	PrevBlockHash HexBytes          `json:"prevBlockHash"`
+	MerkleRoot    HexBytes          `json:"merkleRoot"`
+	Height        int               `json:"height"`
+	Timestamp     time.Time         `json:"timestamp"`
+	TargetBits    int               `json:"targetBits"`
+	Nonce         int               `json:"nonce"`
+	ValidPoW      bool              `json:"validPoW"`
+	Transactions  []TransactionJSON `json:"transactions"`
+}
+
+func newBlockJSON(bc *Blockchain, block *Block) BlockJSON {
+	b := BlockJSON{
+		Hash:          block.Hash,
+		PrevBlockHash: block.PrevBlockHash,
+		MerkleRoot:    block.MerkleRoot,
+		Height:        block.Height,
+		Timestamp:     time.Unix(block.Timestamp, 0).UTC(),
+		TargetBits:    block.TargetBits,
+		Nonce:         block.Nonce,
+		ValidPoW:      NewProofOfWork(block).Validate(bc.TargetBitsAfter(block.PrevBlockHash)),
+		Transactions:  []TransactionJSON{},
+	}
+	for _, tx := range block.Transactions {
+		b.Transactions = append(b.Transactions, newTransactionJSON(tx))
+	}
+
+	return b
+}
+
+// MarshalJSON writes the transaction in its structured form, with its hash
+func (e HistoryEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type        string    `json:"type"`
+		Hash        HexBytes  `json:"hash"`
+		BlockHash   HexBytes  `json:"blockHash"`
+		BlockHeight int       `json:"blockHeight"`
+		BlockTime   time.Time `json:"blockTime"`
+		Transaction any       `json:"transaction"`
+	}{e.Type, TransactionHash(e.Transaction), e.BlockHash, e.BlockHeight, e.BlockTime, e.Transaction.jsonView()})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+// ownerBucket lists every vehicle under its current owner, keyed by the
+// owner's public key, length first as in the canonical encoding, followed by
+// the VIN. The length keeps one owner's keys from running into another's
+// whose key starts the same. Values are empty; the details live in the VIN
+// index.
+const ownerBucket = "owners"
+
+// ownerPrefix returns the part of an owner index key that identifies the owner
+func ownerPrefix(owner []byte) []byte {
+	e := &encoder{}
+	e.writeBytes(owner)
+	return e.Bytes()
+}
+
+func ownerKey(owner []byte, vin string) []byte {
+	return append(ownerPrefix(owner), vin...)
+}
+
+// InventoryItem is a vehicle as it stands in its current owner's hands
+type InventoryItem struct {
+	VIN         string  `json:"vin"`
+	Acquired    ISODate `json:"acquired"`
+	PricePaid   int     `json:"pricePaid"` // 0 when the owner did not buy it
+	ActiveLiens int     `json:"activeLiens"`
+	State       string  `json:"state"`
+}
+
+// Inventory returns the vehicles owner holds right now, in VIN order
+func (bc *Blockchain) Inventory(owner []byte) []InventoryItem {
+	var items []InventoryItem
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		vins := tx.Bucket([]byte(vinBucket))
+		c := tx.Bucket([]byte(ownerBucket)).Cursor()
+
+		prefix := ownerPrefix(owner)
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			record, err := DeserializeVehicleRecord(vins.Get(k[len(prefix):]))
+			if err != nil {
+				return err
+			}
+			items = append(items, InventoryItem{
+				VIN:         record.VIN,
+				Acquired:    ISODate(record.Acquired),
+				PricePaid:   record.PricePaid,
+				ActiveLiens: len(record.activeLiens()),
+				State:       record.State().String(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return items
+}
+
+// moveOwner keeps the owner index in step with a record whose owner may have
+// changed from previous. Deregistered vehicles leave the index.
+func moveOwner(tx *bolt.Tx, previous []byte, record *VehicleRecord) error {
+	b := tx.Bucket([]byte(ownerBucket))
+
+	if previous != nil {
+		if err := b.Delete(ownerKey(previous, record.VIN)); err != nil {
+			return err
+		}
+	}
+	if record.Owner == nil || record.State() == Deregistered {
+		return nil
+	}
+
+	return b.Put(ownerKey(record.Owner, record.VIN), []byte{})
+}
@@ -1,74 +1,168 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/big"
-	"strconv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-const targetBits = 12
+// Difficulty is the number of leading zero bits a block hash must have. It
+// is stored in each block header and re-evaluated every retargetInterval
+// blocks from how long the previous window took to mine.
+const (
+	initialTargetBits = 12
+	minTargetBits     = 8
+	maxTargetBits     = 32
+	retargetInterval  = 10 // blocks
+	targetBlockTime   = 30 // seconds
+)
+
+// maxFutureBlockTime is how far, in seconds, a block's timestamp may run
+// ahead of the validating node's clock
+const maxFutureBlockTime = 2 * targetBlockTime
+
+// MiningProgress is called about once a second while mining with the number
+// of hashes tried so far and the current hash rate
+type MiningProgress func(hashes uint64, hashRate float64)
 
 // ProofOfWork represents a proof-of-work
 type ProofOfWork struct {
-	block  *Block
-	target *big.Int
+	block    *Block
+	target   *big.Int
+	Progress MiningProgress
 }
 
 // NewProofOfWork builds and returns a ProofOfWork
 func NewProofOfWork(b *Block) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	target.Lsh(target, uint(256-b.TargetBits))
 
-	pow := &ProofOfWork{b, target}
+	pow := &ProofOfWork{b, target, nil}
 
 	return pow
 }
 
-func (b *Block) prepareData() []byte {
-	var headers []byte
-	timestamp := []byte(strconv.FormatInt(b.Timestamp, 10))
-	b_nonce := []byte(strconv.Itoa(b.Nonce))
-	headers = append(headers, b.PrevBlockHash...)
-	headers = append(headers, timestamp...)
-	headers = append(headers, b_nonce...)
-	for _, tx := range b.Transactions {
-		txData := (tx).Serialize() // Dereference the pointer and call Serialize on the Transaction
-		headers = append(headers, txData...)
-	}
-
-	return headers
+// headerPrefix returns the canonical encoding of every hashed header field
+// except the nonce, which comes last
+func (b *Block) headerPrefix() []byte {
+	e := &encoder{}
+	e.writeByte(encodingVersion)
+	e.writeBytes(b.PrevBlockHash)
+	e.writeBytes(b.MerkleRoot)
+	e.writeInt64(b.Timestamp)
+	e.writeInt(b.Height)
+	e.writeInt(b.TargetBits)
+
+	return e.Bytes()
 }
 
-// Run performs a proof-of-work
-func (pow *ProofOfWork) Run() []byte {
-	var hashInt big.Int
-	var hash [32]byte
-	pow.block.Nonce = 0
+func (b *Block) prepareData() []byte {
+	return binary.BigEndian.AppendUint64(b.headerPrefix(), uint64(b.Nonce))
+}
 
-	fmt.Printf("Mining the block containing \"%s\"\n", pow.block.Transactions)
-	for pow.block.Nonce < maxNonce {
-		data := pow.block.prepareData()
+// Run performs a proof-of-work across all CPU cores. Worker i tries nonces
+// i, i+n, i+2n, ... so the ranges never overlap. It returns ctx.Err() if the
+// context is cancelled first, e.g. because a competing block arrived.
+func (pow *ProofOfWork) Run(ctx context.Context) ([]byte, error) {
+	type solution struct {
+		nonce int
+		hash  [32]byte
+	}
 
-		hash = sha256.Sum256(data)
-		fmt.Printf("\r%x", hash)
-		hashInt.SetBytes(hash[:])
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := runtime.NumCPU()
+	prefix := pow.block.headerPrefix()
+	found := make(chan solution, workers)
+	var hashes atomic.Uint64
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			var hashInt big.Int
+			data := make([]byte, 0, len(prefix)+8)
+			tried := uint64(0)
+
+			for nonce := start; nonce < maxNonce && nonce >= 0; nonce += workers {
+				// Check for cancellation in batches to keep the loop tight
+				if tried%4096 == 0 {
+					hashes.Add(tried)
+					tried = 0
+					if ctx.Err() != nil {
+						return
+					}
+				}
+
+				data = binary.BigEndian.AppendUint64(append(data[:0], prefix...), uint64(nonce))
+				hash := sha256.Sum256(data)
+				tried++
+				hashInt.SetBytes(hash[:])
+
+				if hashInt.Cmp(pow.target) == -1 {
+					hashes.Add(tried)
+					found <- solution{nonce, hash}
+					cancel()
+					return
+				}
+			}
+		}(w)
+	}
 
-		if hashInt.Cmp(pow.target) == -1 {
-			break
-		} else {
-			pow.block.Nonce++
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	started := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if pow.Progress != nil {
+				total := hashes.Load()
+				pow.Progress(total, float64(total)/time.Since(started).Seconds())
+			}
+		case <-done:
+			select {
+			case result := <-found:
+				pow.block.Nonce = result.nonce
+				return result.hash[:], nil
+			default:
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, errors.New("no nonce satisfies the target")
+			}
 		}
 	}
-	fmt.Print("\n\n")
-
-	return hash[:]
 }
 
-// Validate validates block's PoW
-func (pow *ProofOfWork) Validate() bool {
+// Validate validates block's PoW against the difficulty the chain dictates at
+// its height, and that its Merkle root matches its transactions
+func (pow *ProofOfWork) Validate(expectedBits int) bool {
 	var hashInt big.Int
 
+	if pow.block.TargetBits != expectedBits {
+		return false
+	}
+
+	if !bytes.Equal(pow.block.MerkleRoot, pow.block.HashTransactions()) {
+		return false
+	}
+
 	data := pow.block.prepareData()
 	hash := sha256.Sum256(data)
 	hashInt.SetBytes(hash[:])
@@ -77,3 +171,78 @@ func (pow *ProofOfWork) Validate() bool {
 
 	return isValid
 }
+
+// nextTargetBits applies the retarget rule for the block following the last
+// one in window. window holds up to retargetInterval blocks ending at the
+// current tip, oldest first; it is empty when the next block is the genesis.
+func nextTargetBits(window []*Block) int {
+	if len(window) == 0 {
+		return initialTargetBits
+	}
+
+	prev := window[len(window)-1]
+	height := prev.Height + 1
+	if height%retargetInterval != 0 || len(window) < retargetInterval {
+		return prev.TargetBits
+	}
+
+	// One bit halves or doubles the work, so only move when the window was
+	// more than twice as fast or as slow as intended
+	actual := prev.Timestamp - window[0].Timestamp
+	expected := int64(retargetInterval-1) * targetBlockTime
+	bits := prev.TargetBits
+	switch {
+	case actual < expected/2:
+		bits++
+	case actual > expected*2:
+		bits--
+	}
+
+	if bits < minTargetBits {
+		bits = minTargetBits
+	}
+	if bits > maxTargetBits {
+		bits = maxTargetBits
+	}
+
+	return bits
+}
+
+// checkTimestamp applies the timestamp rule to a block following the last one
+// in window, as judged at time now. Retargeting trusts block timestamps, so a
+// block may not be dated before its parent, nor further than
+// maxFutureBlockTime ahead of now. Timestamps have one-second resolution, so
+// blocks mined in quick succession may share one.
+func checkTimestamp(window []*Block, timestamp, now int64) error {
+	if len(window) > 0 {
+		if parent := window[len(window)-1]; timestamp < parent.Timestamp {
+			return fmt.Errorf("timestamp %s is before its parent's, %s", formatBlockTime(timestamp), formatBlockTime(parent.Timestamp))
+		}
+	}
+	if timestamp > now+maxFutureBlockTime {
+		return fmt.Errorf("timestamp %s is more than %d seconds in the future", formatBlockTime(timestamp), maxFutureBlockTime)
+	}
+
+	return nil
+}
+
+func formatBlockTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+// retargetWindow loads the retargetInterval blocks ending at hash, oldest first
+func (bc *Blockchain) retargetWindow(hash []byte) []*Block {
+	var window []*Block
+
+	bci := &BlockchainIterator{hash, bc.db}
+	for len(window) < retargetInterval && len(bci.currentHash) > 0 {
+		window = append([]*Block{bci.Next()}, window...)
+	}
+
+	return window
+}
+
+// TargetBitsAfter returns the difficulty required of a block whose parent is prevHash
+func (bc *Blockchain) TargetBitsAfter(prevHash []byte) int {
+	return nextTargetBits(bc.retargetWindow(prevHash))
+}
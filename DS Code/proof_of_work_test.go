@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+// retargetWindow returns size blocks ending at height tip, the first dated 0
+// and the last dated span, all mined at bits
+func retargetWindow(size, tip int, span int64, bits int) []*Block {
+	window := make([]*Block, size)
+	for i := range window {
+		window[i] = &Block{Height: tip - size + 1 + i, TargetBits: bits}
+	}
+	if size > 0 {
+		window[size-1].Timestamp = span
+	}
+
+	return window
+}
+
+func TestNextTargetBits(t *testing.T) {
+	expected := int64(retargetInterval-1) * targetBlockTime
+
+	tests := []struct {
+		name   string
+		window []*Block
+		want   int
+	}{
+		{"genesis", nil, initialTargetBits},
+		{"just under half the expected time", retargetWindow(retargetInterval, retargetInterval-1, expected/2-1, 12), 13},
+		{"exactly half the expected time", retargetWindow(retargetInterval, retargetInterval-1, expected/2, 12), 12},
+		{"on time", retargetWindow(retargetInterval, retargetInterval-1, expected, 12), 12},
+		{"exactly twice the expected time", retargetWindow(retargetInterval, retargetInterval-1, expected*2, 12), 12},
+		{"just over twice the expected time", retargetWindow(retargetInterval, retargetInterval-1, expected*2+1, 12), 11},
+		{"later interval", retargetWindow(retargetInterval, 3*retargetInterval-1, 0, 12), 13},
+		{"between retargets", retargetWindow(retargetInterval, retargetInterval, 0, 12), 12},
+		{"window shorter than the interval", retargetWindow(retargetInterval-1, retargetInterval-1, 0, 12), 12},
+		{"capped at the maximum", retargetWindow(retargetInterval, retargetInterval-1, 0, maxTargetBits), maxTargetBits},
+		{"floored at the minimum", retargetWindow(retargetInterval, retargetInterval-1, expected*10, minTargetBits), minTargetBits},
+	}
+
+	for _, tt := range tests {
+		if got := nextTargetBits(tt.window); got != tt.want {
+			t.Errorf("%s: got %d bits, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	window := []*Block{{Timestamp: 1000}}
+	now := int64(2000)
+
+	tests := []struct {
+		timestamp int64
+		ok        bool
+	}{
+		{999, false},
+		{1000, true},
+		{now + maxFutureBlockTime, true},
+		{now + maxFutureBlockTime + 1, false},
+	}
+
+	for _, tt := range tests {
+		if err := checkTimestamp(window, tt.timestamp, now); (err == nil) != tt.ok {
+			t.Errorf("checkTimestamp(%d) = %v, want ok %v", tt.timestamp, err, tt.ok)
+		}
+	}
+}
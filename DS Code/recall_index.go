@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+// recallBucket maps each recall campaign to its RecallNotice in the canonical encoding
+const recallBucket = "recalls"
+
+// FindRecall returns the notice that opened campaign, or nil if there is none
+func (bc *Blockchain) FindRecall(campaign string) *RecallNotice {
+	var notice *RecallNotice
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(recallBucket)).Get([]byte(campaign))
+		if data == nil {
+			return nil
+		}
+
+		notice = &RecallNotice{}
+		return notice.Deserialize(data)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return notice
+}
+
+// Recalls returns the recalls affecting vin, split into those still open and
+// those remedied, each in campaign order
+func (bc *Blockchain) Recalls(vin string) (open, remedied []*RecallNotice) {
+	record := bc.FindVehicle(vin)
+	if record == nil {
+		record = &VehicleRecord{VIN: vin}
+	}
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(recallBucket)).ForEach(func(k, data []byte) error {
+			notice := &RecallNotice{}
+			if err := notice.Deserialize(data); err != nil {
+				return err
+			}
+			switch {
+			case !notice.affects(vin):
+			case record.remedied(notice.Campaign):
+				remedied = append(remedied, notice)
+			default:
+				open = append(open, notice)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return open, remedied
+}
+
+// putRecall adds a recall campaign to the index
+func putRecall(tx *bolt.Tx, rn *RecallNotice) error {
+	return tx.Bucket([]byte(recallBucket)).Put([]byte(rn.Campaign), rn.Serialize())
+}
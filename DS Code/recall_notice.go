@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"Take1_Autochain/vin"
+)
+
+func init() {
+	RegisterTransactionType("RecallNotice", func() Transaction { return &RecallNotice{} }, &CLISpec{
+		Usage: "-campaign ID -manufacturer MANUFACTURER -description TEXT -date DATE [-vins VIN,...] [-ranges FIRST-LAST,...]",
+		Run:   (*CLI).addRecallNotice,
+	})
+}
+
+// RecallNotice opens a recall campaign on a list of VINs and ranges of them.
+// It must be signed by the manufacturer authorized for every WMI it names.
+// The recall stays open on each affected vehicle until a RecallRemedy for
+// the campaign is recorded against it.
+type RecallNotice struct {
+	Nonce
+	Campaign     string // Manufacturer's campaign identifier, unique on chain
+	Manufacturer []byte
+	Description  string
+	VINs         []string
+	Ranges       []VINRange
+	NoticeDate   int64
+	Signature    []byte // By Manufacturer
+}
+
+// VINRange covers the VINs of one model line, year and plant whose serial
+// numbers lie between First's and Last's. The check digit is ignored.
+type VINRange struct {
+	First string
+	Last  string
+}
+
+func (r VINRange) validate() error {
+	if err := vin.Validate(r.First); err != nil {
+		return fmt.Errorf("range start %s: %w", r.First, err)
+	}
+	if err := vin.Validate(r.Last); err != nil {
+		return fmt.Errorf("range end %s: %w", r.Last, err)
+	}
+	if r.First[:8] != r.Last[:8] || r.First[9:11] != r.Last[9:11] {
+		return fmt.Errorf("range %s: both ends must share the model, year and plant", r)
+	}
+	if r.First[11:] > r.Last[11:] {
+		return fmt.Errorf("range %s: the first serial number is after the last", r)
+	}
+
+	return nil
+}
+
+func (r VINRange) contains(v string) bool {
+	return len(v) == vin.Length && v[:8] == r.First[:8] && v[9:11] == r.First[9:11] &&
+		v[11:] >= r.First[11:] && v[11:] <= r.Last[11:]
+}
+
+func (r VINRange) String() string {
+	return r.First + "-" + r.Last
+}
+
+func (vr *RecallNotice) ID() string {
+	return vr.Campaign
+}
+
+// affects reports whether the recall covers the VIN
+func (vr *RecallNotice) affects(v string) bool {
+	for _, listed := range vr.VINs {
+		if listed == v {
+			return true
+		}
+	}
+	for _, r := range vr.Ranges {
+		if r.contains(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wmis returns the manufacturer identifiers of every VIN the recall names
+func (vr *RecallNotice) wmis() []string {
+	seen := make(map[string]bool)
+	var wmis []string
+	add := func(v string) {
+		if wmi := v[:3]; !seen[wmi] {
+			seen[wmi] = true
+			wmis = append(wmis, wmi)
+		}
+	}
+	for _, v := range vr.VINs {
+		add(v)
+	}
+	for _, r := range vr.Ranges {
+		add(r.First)
+	}
+
+	return wmis
+}
+
+func (vr *RecallNotice) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.Campaign)
+	e.writeBytes(vr.Manufacturer)
+	e.writeString(vr.Description)
+	e.writeStrings(vr.VINs)
+	e.writeUint32(uint32(len(vr.Ranges)))
+	for _, r := range vr.Ranges {
+		e.writeString(r.First)
+		e.writeString(r.Last)
+	}
+	e.writeInt64(vr.NoticeDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *RecallNotice) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.Campaign = d.readString()
+	vr.Manufacturer = d.readBytes()
+	vr.Description = d.readString()
+	vr.VINs = d.readStrings()
+	for n := d.readCount(); n > 0; n-- {
+		vr.Ranges = append(vr.Ranges, VINRange{First: d.readString(), Last: d.readString()})
+	}
+	vr.NoticeDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *RecallNotice) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *RecallNotice) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Manufacturer, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *RecallNotice) print_transaction() {
+	fmt.Println("Recall Notice Transaction")
+	fmt.Printf("Campaign: %s\n", vr.Campaign)
+	fmt.Printf("Manufacturer: %x\n", vr.Manufacturer)
+	fmt.Printf("Description: %s\n", vr.Description)
+	if len(vr.VINs) > 0 {
+		fmt.Printf("VINs: %s\n", strings.Join(vr.VINs, ", "))
+	}
+	for _, r := range vr.Ranges {
+		fmt.Printf("VIN Range: %s\n", r)
+	}
+	fmt.Printf("Notice Date: %s\n", time.Unix(vr.NoticeDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *RecallNotice) jsonView() any {
+	type vinRange struct {
+		First string `json:"first"`
+		Last  string `json:"last"`
+	}
+	ranges := make([]vinRange, len(vr.Ranges))
+	for i, r := range vr.Ranges {
+		ranges[i] = vinRange{r.First, r.Last}
+	}
+
+	return struct {
+		Campaign     string     `json:"campaign"`
+		Manufacturer HexBytes   `json:"manufacturer"`
+		Description  string     `json:"description"`
+		VINs         []string   `json:"vins,omitempty"`
+		Ranges       []vinRange `json:"ranges,omitempty"`
+		NoticeDate   ISODate    `json:"noticeDate"`
+		Signature    HexBytes   `json:"signature"`
+	}{vr.Campaign, vr.Manufacturer, vr.Description, vr.VINs, ranges, ISODate(vr.NoticeDate), vr.Signature}
+}
+
+func (cli *CLI) addRecallNotice(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("RecallNotice", flag.ExitOnError)
+	campaign := cmd.String("campaign", "", "Recall campaign identifier")
+	manufacturer := cmd.String("manufacturer", "", "Manufacturer's local identity, who signs the notice")
+	description := cmd.String("description", "", "Defect and remedy")
+	date := cmd.String("date", "", "Notice date in YYYY-MM-DD format")
+	vins := cmd.String("vins", "", "Comma-separated VINs affected")
+	ranges := cmd.String("ranges", "", "Comma-separated VIN ranges affected, each written FIRST-LAST")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if *campaign == "" || *description == "" {
+		fmt.Println("A campaign identifier and description are required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *manufacturer == "" {
+		fmt.Println("A manufacturer's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	noticeDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid notice date format. Use YYYY-MM-DD.")
+	}
+
+	rn := &RecallNotice{Campaign: *campaign, Description: *description, NoticeDate: noticeDate.Unix()}
+	for _, v := range strings.Split(*vins, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			cli.checkVIN(v)
+			rn.VINs = append(rn.VINs, v)
+		}
+	}
+	for _, s := range strings.Split(*ranges, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(s, "-")
+		r := VINRange{First: first, Last: last}
+		if !ok {
+			err = fmt.Errorf("range %s must be written FIRST-LAST", s)
+		} else {
+			err = r.validate()
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		rn.Ranges = append(rn.Ranges, r)
+	}
+	if len(rn.VINs) == 0 && len(rn.Ranges) == 0 {
+		fmt.Println("The recall must name affected VINs or VIN ranges.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	manufacturerID := cli.identity(*manufacturer)
+	rn.Manufacturer = manufacturerID.PublicKey
+	rn.Sign(manufacturerID.PrivateKey)
+
+	// Manufacturer checks run against the chain and the pending pool
+	cli.submit(rn)
+
+	fmt.Println("Recall notice transaction added to the pending pool!")
+}
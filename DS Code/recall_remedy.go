@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("RecallRemedy", func() Transaction { return &RecallRemedy{} }, &CLISpec{
+		Usage: "-vin VIN -campaign ID -station STATION -date DATE",
+		Run:   (*CLI).addRecallRemedy,
+	})
+}
+
+// RecallRemedy records that a service station carried out a recall's fix on
+// the vehicle, closing the recall for it. The station must hold the
+// service-station role, unless it is the recalling manufacturer itself.
+type RecallRemedy struct {
+	Nonce
+	VIN        string
+	Campaign   string
+	Station    []byte
+	RemedyDate int64
+	Signature  []byte // By Station
+}
+
+func (vr *RecallRemedy) ID() string {
+	return vr.VIN
+}
+
+func (vr *RecallRemedy) SourceStates() []VehicleState {
+	return activeStates
+}
+
+func (vr *RecallRemedy) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeString(vr.Campaign)
+	e.writeBytes(vr.Station)
+	e.writeInt64(vr.RemedyDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *RecallRemedy) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Campaign = d.readString()
+	vr.Station = d.readBytes()
+	vr.RemedyDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *RecallRemedy) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *RecallRemedy) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Station, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *RecallRemedy) print_transaction() {
+	fmt.Println("Recall Remedy Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Campaign: %s\n", vr.Campaign)
+	fmt.Printf("Station: %x\n", vr.Station)
+	fmt.Printf("Remedy Date: %s\n", time.Unix(vr.RemedyDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *RecallRemedy) jsonView() any {
+	return struct {
+		VIN        string   `json:"vin"`
+		Campaign   string   `json:"campaign"`
+		Station    HexBytes `json:"station"`
+		RemedyDate ISODate  `json:"remedyDate"`
+		Signature  HexBytes `json:"signature"`
+	}{vr.VIN, vr.Campaign, vr.Station, ISODate(vr.RemedyDate), vr.Signature}
+}
+
+func (cli *CLI) addRecallRemedy(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("RecallRemedy", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	campaign := cmd.String("campaign", "", "Recall campaign identifier")
+	station := cmd.String("station", "", "Local identity of the service station or recalling manufacturer, who signs the remedy")
+	date := cmd.String("date", "", "Remedy date in YYYY-MM-DD format")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *campaign == "" {
+		fmt.Println("A campaign identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *station == "" {
+		fmt.Println("A service station's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	remedyDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid remedy date format. Use YYYY-MM-DD.")
+	}
+
+	stationID := cli.identity(*station)
+
+	rr := &RecallRemedy{VIN: *vin, Campaign: *campaign, Station: stationID.PublicKey, RemedyDate: remedyDate.Unix()}
+	rr.Sign(stationID.PrivateKey)
+
+	cli.submit(rr)
+
+	fmt.Println("Recall remedy transaction added to the pending pool!")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"reflect"
+)
+
+// CLISpec describes how a transaction type is created from the command line.
+// Registering one adds an "addblock NAME" subcommand and its usage line.
+type CLISpec struct {
+	Usage string                        // Flags shown in the usage text, e.g. "-vin VIN -owner OWNER"
+	Run   func(cli *CLI, args []string) // Parses the flags, then builds, signs and submits the transaction
+}
+
+type transactionTypeInfo struct {
+	name    string
+	factory func() Transaction
+	cli     *CLISpec
+}
+
+var (
+	transactionRegistry = make(map[string]*transactionTypeInfo)
+	transactionNames    = make(map[reflect.Type]string)
+	registrationOrder   []string // Keeps usage text in a stable order
+)
+
+// RegisterTransactionType makes a transaction type known to block decoding,
+// the mempool and, when cliSpec is non-nil, the addblock command. factory
+// must return a new zero value as a pointer. Types register from init, and
+// registering the same name or Go type twice panics.
+func RegisterTransactionType(name string, factory func() Transaction, cliSpec *CLISpec) {
+	if _, ok := transactionRegistry[name]; ok {
+		panic(fmt.Sprintf("transaction type %q registered twice", name))
+	}
+
+	goType := reflect.TypeOf(factory())
+	if other, ok := transactionNames[goType]; ok {
+		panic(fmt.Sprintf("Go type %s already registered as %q", goType, other))
+	}
+
+	transactionRegistry[name] = &transactionTypeInfo{name, factory, cliSpec}
+	transactionNames[goType] = name
+	registrationOrder = append(registrationOrder, name)
+
+	// Needed to decode blocks and pending transactions stored in the legacy gob format
+	gob.Register(factory())
+}
+
+// NewTransaction returns an empty transaction of the named type
+func NewTransaction(name string) (Transaction, error) {
+	info, ok := transactionRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction type %q", name)
+	}
+
+	return info.factory(), nil
+}
+
+// transactionType returns the name a transaction is registered and stored under
+func transactionType(tx Transaction) string {
+	if name, ok := transactionNames[reflect.TypeOf(tx)]; ok {
+		return name
+	}
+
+	return reflect.TypeOf(tx).Elem().Name()
+}
+
+// cliTransactionTypes returns the types that can be created with addblock, in registration order
+func cliTransactionTypes() []*transactionTypeInfo {
+	var infos []*transactionTypeInfo
+	for _, name := range registrationOrder {
+		if info := transactionRegistry[name]; info.cli != nil {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
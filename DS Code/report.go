@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+// financingWindow is how long after a sale, in seconds, a loan the buyer
+// takes out on the same vehicle still counts as financing the purchase.
+// Loans on the buyer's other vehicles never do.
+const financingWindow = 30 * 24 * 60 * 60
+
+// SalesSummary aggregates a group of sales: one dealer's in one month, or
+// every sale in the report for the total
+type SalesSummary struct {
+	Dealer                 HexBytes `json:"dealer,omitempty"`
+	Month                  string   `json:"month,omitempty"` // YYYY-MM
+	Sales                  int      `json:"sales"`
+	Revenue                int      `json:"revenue"`
+	AveragePrice           float64  `json:"averagePrice"`
+	MedianPrice            float64  `json:"medianPrice"`
+	AverageDaysInInventory float64  `json:"averageDaysInInventory"` // From the dealer acquiring the vehicle to selling it
+	LoanPenetration        float64  `json:"loanPenetration"`        // Share of sales financed, from 0 to 1
+}
+
+// saleFact is one sale as the report sees it
+type saleFact struct {
+	dealer   []byte
+	buyer    []byte
+	date     int64
+	price    int
+	days     int  // In the dealer's inventory; -1 when the acquisition is not on chain
+	financed bool // By the buyer's loan on this vehicle, taking over the lien at closing or taken out soon after
+}
+
+// SalesReport summarizes the sales dated between from and to, inclusive, per
+// dealer per month and in total. Rows are ordered by dealer, then month.
+func (bc *Blockchain) SalesReport(from, to int64) ([]SalesSummary, SalesSummary, error) {
+	blocks, err := bc.BlocksFromGenesis()
+	if err != nil {
+		return nil, SalesSummary{}, err
+	}
+
+	var facts []*saleFact
+	owners := make(map[string][]byte)
+	acquired := make(map[string]int64)
+	lastSale := make(map[string]*saleFact)
+
+	acquire := func(vin string, owner []byte, date int64) {
+		owners[vin] = owner
+		acquired[vin] = date
+	}
+
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			switch tx := tx.(type) {
+			case *ManufacturerIssue:
+				acquire(tx.VIN, tx.Manufacturer, tx.IssueDate)
+			case *VehicleRegistration:
+				if owners[tx.VIN] == nil {
+					acquire(tx.VIN, tx.Owner, tx.RegistrationDate)
+				}
+			case *VehicleSale:
+				fact := &saleFact{dealer: tx.Dealer, buyer: tx.Buyer, date: tx.SaleDate, price: tx.Price, days: -1, financed: tx.BuyerLoan != nil}
+				if since, ok := acquired[tx.VIN]; ok && bytes.Equal(owners[tx.VIN], tx.Dealer) {
+					fact.days = int((tx.SaleDate - since) / (24 * 60 * 60))
+				}
+				if tx.SaleDate >= from && tx.SaleDate <= to {
+					facts = append(facts, fact)
+				}
+				lastSale[tx.VIN] = fact
+				acquire(tx.VIN, tx.Buyer, tx.SaleDate)
+			case *LoanContract:
+				// Matched by the loan's own VIN, so only the latest sale of the
+				// vehicle the buyer borrows against counts as financed
+				if sale := lastSale[tx.VIN]; sale != nil && bytes.Equal(sale.buyer, tx.Borrower) &&
+					tx.StartDate >= sale.date && tx.StartDate <= sale.date+financingWindow {
+					sale.financed = true
+				}
+			}
+		}
+	}
+
+	groups := make(map[string][]*saleFact)
+	for _, fact := range facts {
+		key := string(fact.dealer) + "/" + time.Unix(fact.date, 0).UTC().Format("2006-01")
+		groups[key] = append(groups[key], fact)
+	}
+
+	var rows []SalesSummary
+	for _, group := range groups {
+		row := summarizeSales(group)
+		row.Dealer = group[0].dealer
+		row.Month = time.Unix(group[0].date, 0).UTC().Format("2006-01")
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if c := bytes.Compare(rows[i].Dealer, rows[j].Dealer); c != 0 {
+			return c < 0
+		}
+		return rows[i].Month < rows[j].Month
+	})
+
+	return rows, summarizeSales(facts), nil
+}
+
+func summarizeSales(facts []*saleFact) SalesSummary {
+	var s SalesSummary
+	if len(facts) == 0 {
+		return s
+	}
+
+	var prices []int
+	var financed, stocked, days int
+	for _, fact := range facts {
+		s.Sales++
+		s.Revenue += fact.price
+		prices = append(prices, fact.price)
+		if fact.financed {
+			financed++
+		}
+		if fact.days >= 0 {
+			stocked++
+			days += fact.days
+		}
+	}
+
+	sort.Ints(prices)
+	n := len(prices)
+	s.MedianPrice = float64(prices[n/2])
+	if n%2 == 0 {
+		s.MedianPrice = float64(prices[n/2-1]+prices[n/2]) / 2
+	}
+	s.AveragePrice = float64(s.Revenue) / float64(n)
+	s.LoanPenetration = float64(financed) / float64(n)
+	if stocked > 0 {
+		s.AverageDaysInInventory = float64(days) / float64(stocked)
+	}
+
+	return s
+}
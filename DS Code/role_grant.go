@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func init() {
+	RegisterTransactionType("RoleGrant", func() Transaction { return &RoleGrant{} }, &CLISpec{
+		Usage: "-role dmv|insurer|law-enforcement|service-station -grantee GRANTEE -name NAME -authority AUTHORITY",
+		Run:   (*CLI).addRoleGrant,
+	})
+}
+
+// RoleGrant gives an identity a role, such as insurer, that some transaction
+// types require of their signer. Only the chain authority may sign one.
+type RoleGrant struct {
+	Nonce
+	Role      string
+	Grantee   []byte
+	Name      string
+	Authority []byte
+	Signature []byte // By Authority
+}
+
+func (vr *RoleGrant) ID() string {
+	return vr.Role
+}
+
+func (vr *RoleGrant) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.Role)
+	e.writeBytes(vr.Grantee)
+	e.writeString(vr.Name)
+	e.writeBytes(vr.Authority)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *RoleGrant) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.Role = d.readString()
+	vr.Grantee = d.readBytes()
+	vr.Name = d.readString()
+	vr.Authority = d.readBytes()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *RoleGrant) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *RoleGrant) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Authority, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *RoleGrant) print_transaction() {
+	fmt.Println("Role Grant Transaction")
+	fmt.Printf("Role: %s\n", vr.Role)
+	fmt.Printf("Grantee: %s (%x)\n", vr.Name, vr.Grantee)
+	fmt.Printf("Authority: %x\n", vr.Authority)
+}
+
+func (vr *RoleGrant) jsonView() any {
+	return struct {
+		Role      string   `json:"role"`
+		Grantee   HexBytes `json:"grantee"`
+		Name      string   `json:"name"`
+		Authority HexBytes `json:"authority"`
+		Signature HexBytes `json:"signature"`
+	}{vr.Role, vr.Grantee, vr.Name, vr.Authority, vr.Signature}
+}
+
+func (cli *CLI) addRoleGrant(args []string) {
+	cmd := flag.NewFlagSet("RoleGrant", flag.ExitOnError)
+	role := cmd.String("role", "", "Role to grant: dmv, insurer, law-enforcement or service-station")
+	grantee := cmd.String("grantee", "", "Identity or public key receiving the role")
+	name := cmd.String("name", "", "Grantee's name")
+	authority := cmd.String("authority", "", "Chain authority's local identity, who signs the grant")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !validRole(*role) {
+		fmt.Println("Role must be dmv, insurer, law-enforcement or service-station.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *grantee == "" || *name == "" {
+		fmt.Println("The grantee's identifier and name are required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *authority == "" {
+		fmt.Println("The chain authority's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	authorityID := cli.identity(*authority)
+
+	rg := &RoleGrant{
+		Role:      *role,
+		Grantee:   cli.party(*grantee),
+		Name:      *name,
+		Authority: authorityID.PublicKey,
+	}
+	rg.Sign(authorityID.PrivateKey)
+
+	cli.submit(rg)
+
+	fmt.Println("Role grant transaction added to the pending pool!")
+}
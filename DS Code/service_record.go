@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("ServiceRecord", func() Transaction { return &ServiceRecord{} }, &CLISpec{
+		Usage: "-vin VIN -station STATION -odometer MILES -work DESCRIPTION -date DATE [-odometer-replaced]",
+		Run:   (*CLI).addServiceRecord,
+	})
+}
+
+// ServiceRecord is a service station's log of work done on a vehicle and
+// the odometer reading it saw. Readings may never go down unless the station
+// declares that the odometer itself was replaced, which is flagged in the
+// vehicle's history.
+type ServiceRecord struct {
+	Nonce
+	VIN              string
+	Station          []byte
+	Odometer         int
+	WorkPerformed    string
+	ServiceDate      int64
+	OdometerReplaced bool
+	Signature        []byte // By Station
+}
+
+func (vr *ServiceRecord) ID() string {
+	return vr.VIN
+}
+
+func (vr *ServiceRecord) SourceStates() []VehicleState {
+	return activeStates
+}
+
+func (vr *ServiceRecord) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Station)
+	e.writeInt(vr.Odometer)
+	e.writeString(vr.WorkPerformed)
+	e.writeInt64(vr.ServiceDate)
+	e.writeBool(vr.OdometerReplaced)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *ServiceRecord) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Station = d.readBytes()
+	vr.Odometer = d.readInt()
+	vr.WorkPerformed = d.readString()
+	vr.ServiceDate = d.readInt64()
+	vr.OdometerReplaced = d.readBool()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *ServiceRecord) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *ServiceRecord) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Station, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *ServiceRecord) print_transaction() {
+	fmt.Println("Service Record Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Station: %x\n", vr.Station)
+	fmt.Printf("Odometer: %d\n", vr.Odometer)
+	if vr.OdometerReplaced {
+		fmt.Println("Odometer replaced: reading restarts here")
+	}
+	fmt.Printf("Work Performed: %s\n", vr.WorkPerformed)
+	fmt.Printf("Service Date: %s\n", time.Unix(vr.ServiceDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *ServiceRecord) jsonView() any {
+	return struct {
+		VIN              string   `json:"vin"`
+		Station          HexBytes `json:"station"`
+		Odometer         int      `json:"odometer"`
+		WorkPerformed    string   `json:"workPerformed"`
+		ServiceDate      ISODate  `json:"serviceDate"`
+		OdometerReplaced bool     `json:"odometerReplaced"`
+		Signature        HexBytes `json:"signature"`
+	}{vr.VIN, vr.Station, vr.Odometer, vr.WorkPerformed, ISODate(vr.ServiceDate), vr.OdometerReplaced, vr.Signature}
+}
+
+func (cli *CLI) addServiceRecord(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("ServiceRecord", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	station := cmd.String("station", "", "Service station's local identity, who signs the record")
+	odometer := cmd.Int("odometer", -1, "Odometer reading")
+	work := cmd.String("work", "", "Description of the work performed")
+	date := cmd.String("date", "", "Service date in YYYY-MM-DD format")
+	replaced := cmd.Bool("odometer-replaced", false, "The odometer was replaced, so a lower reading is expected")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *station == "" {
+		fmt.Println("A service station identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *odometer < 0 {
+		fmt.Println("An odometer reading of 0 or more is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *work == "" {
+		fmt.Println("A description of the work performed is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	serviceDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid service date format. Use YYYY-MM-DD.")
+	}
+
+	stationID := cli.identity(*station)
+
+	// Create and sign the ServiceRecord transaction
+	sr := &ServiceRecord{
+		VIN:              *vin,
+		Station:          stationID.PublicKey,
+		Odometer:         *odometer,
+		WorkPerformed:    *work,
+		ServiceDate:      serviceDate.Unix(),
+		OdometerReplaced: *replaced,
+	}
+	sr.Sign(stationID.PrivateKey)
+
+	// Odometer checks run against the chain and the pending pool
+	cli.submit(sr)
+
+	fmt.Println("Service record transaction added to the pending pool!")
+}
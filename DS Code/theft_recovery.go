@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("TheftRecovery", func() Transaction { return &TheftRecovery{} }, &CLISpec{
+		Usage: "-vin VIN -issuer ISSUER -date DATE [-case CASE]",
+		Run:   (*CLI).addTheftRecovery,
+	})
+}
+
+// TheftRecovery closes the open theft report on the vehicle, returning it to
+// the state it was in before. Like the report, it must be signed by law
+// enforcement or a DMV.
+type TheftRecovery struct {
+	Nonce
+	VIN          string
+	CaseNumber   string // Must match the open TheftReport
+	Issuer       []byte
+	RecoveryDate int64
+	Signature    []byte // By Issuer
+}
+
+func (vr *TheftRecovery) ID() string {
+	return vr.VIN
+}
+
+func (vr *TheftRecovery) SourceStates() []VehicleState {
+	return []VehicleState{Stolen}
+}
+
+func (vr *TheftRecovery) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeString(vr.CaseNumber)
+	e.writeBytes(vr.Issuer)
+	e.writeInt64(vr.RecoveryDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *TheftRecovery) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.CaseNumber = d.readString()
+	vr.Issuer = d.readBytes()
+	vr.RecoveryDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *TheftRecovery) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *TheftRecovery) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Issuer, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *TheftRecovery) print_transaction() {
+	fmt.Println("Theft Recovery Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Case Number: %s\n", vr.CaseNumber)
+	fmt.Printf("Issuer: %x\n", vr.Issuer)
+	fmt.Printf("Recovery Date: %s\n", time.Unix(vr.RecoveryDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *TheftRecovery) jsonView() any {
+	return struct {
+		VIN          string   `json:"vin"`
+		CaseNumber   string   `json:"caseNumber"`
+		Issuer       HexBytes `json:"issuer"`
+		RecoveryDate ISODate  `json:"recoveryDate"`
+		Signature    HexBytes `json:"signature"`
+	}{vr.VIN, vr.CaseNumber, vr.Issuer, ISODate(vr.RecoveryDate), vr.Signature}
+}
+
+func (cli *CLI) addTheftRecovery(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("TheftRecovery", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	caseNumber := cmd.String("case", "", "Case number of the theft report; the open one by default")
+	issuer := cmd.String("issuer", "", "Local identity of the law enforcement agency or DMV, who signs the recovery")
+	date := cmd.String("date", "", "Recovery date in YYYY-MM-DD format")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *issuer == "" {
+		fmt.Println("An issuer's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	recoveryDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid recovery date format. Use YYYY-MM-DD.")
+	}
+
+	if *caseNumber == "" {
+		if record := cli.bc.FindVehicle(*vin); record != nil && record.Theft != nil {
+			*caseNumber = record.Theft.CaseNumber
+		}
+	}
+
+	issuerID := cli.identity(*issuer)
+
+	tr := &TheftRecovery{VIN: *vin, CaseNumber: *caseNumber, Issuer: issuerID.PublicKey, RecoveryDate: recoveryDate.Unix()}
+	tr.Sign(issuerID.PrivateKey)
+
+	// Role and case checks run against the chain and the pending pool
+	cli.submit(tr)
+
+	fmt.Println("Theft recovery transaction added to the pending pool!")
+}
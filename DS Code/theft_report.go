@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("TheftReport", func() Transaction { return &TheftReport{} }, &CLISpec{
+		Usage: "-vin VIN -case CASE -issuer ISSUER -date DATE",
+		Run:   (*CLI).addTheftReport,
+	})
+}
+
+// TheftReport flags the vehicle as stolen, freezing sales, loans and service
+// until a TheftRecovery closes the case. It must be signed by law enforcement
+// or a DMV.
+type TheftReport struct {
+	Nonce
+	VIN        string
+	CaseNumber string
+	Issuer     []byte
+	TheftDate  int64
+	Signature  []byte // By Issuer
+}
+
+func (vr *TheftReport) ID() string {
+	return vr.VIN
+}
+
+func (vr *TheftReport) SourceStates() []VehicleState {
+	return activeStates
+}
+
+func (vr *TheftReport) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeString(vr.CaseNumber)
+	e.writeBytes(vr.Issuer)
+	e.writeInt64(vr.TheftDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *TheftReport) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.CaseNumber = d.readString()
+	vr.Issuer = d.readBytes()
+	vr.TheftDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *TheftReport) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *TheftReport) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Issuer, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *TheftReport) print_transaction() {
+	fmt.Println("Theft Report Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Case Number: %s\n", vr.CaseNumber)
+	fmt.Printf("Issuer: %x\n", vr.Issuer)
+	fmt.Printf("Theft Date: %s\n", time.Unix(vr.TheftDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *TheftReport) jsonView() any {
+	return struct {
+		VIN        string   `json:"vin"`
+		CaseNumber string   `json:"caseNumber"`
+		Issuer     HexBytes `json:"issuer"`
+		TheftDate  ISODate  `json:"theftDate"`
+		Signature  HexBytes `json:"signature"`
+	}{vr.VIN, vr.CaseNumber, vr.Issuer, ISODate(vr.TheftDate), vr.Signature}
+}
+
+func (cli *CLI) addTheftReport(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("TheftReport", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	caseNumber := cmd.String("case", "", "Police case number")
+	issuer := cmd.String("issuer", "", "Local identity of the law enforcement agency or DMV, who signs the report")
+	date := cmd.String("date", "", "Theft date in YYYY-MM-DD format")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if *caseNumber == "" {
+		fmt.Println("A case number is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *issuer == "" {
+		fmt.Println("An issuer's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	theftDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid theft date format. Use YYYY-MM-DD.")
+	}
+
+	issuerID := cli.identity(*issuer)
+
+	tr := &TheftReport{VIN: *vin, CaseNumber: *caseNumber, Issuer: issuerID.PublicKey, TheftDate: theftDate.Unix()}
+	tr.Sign(issuerID.PrivateKey)
+
+	// Role checks run against the chain and the pending pool
+	cli.submit(tr)
+
+	fmt.Println("Theft report transaction added to the pending pool!")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("TitleBrand", func() Transaction { return &TitleBrand{} }, &CLISpec{
+		Usage: "-vin VIN -brand BRAND -issuer ISSUER -date DATE",
+		Run:   (*CLI).addTitleBrand,
+	})
+}
+
+// titleBrands are the brands a title can carry. A rebuilt brand is added on
+// top of the salvage or total-loss brand it follows; no brand is ever removed.
+var titleBrands = []string{"salvage", "rebuilt", "flood", "total-loss"}
+
+// TitleBrand permanently marks the vehicle's title, for instance after an
+// insurer declares it a total loss. It must be signed by a DMV or an insurer.
+type TitleBrand struct {
+	Nonce
+	VIN       string
+	Brand     string // One of titleBrands
+	Issuer    []byte
+	BrandDate int64
+	Signature []byte // By Issuer
+}
+
+func (vr *TitleBrand) ID() string {
+	return vr.VIN
+}
+
+// An insurer can brand a stolen vehicle it has settled as a total loss
+func (vr *TitleBrand) SourceStates() []VehicleState {
+	return []VehicleState{Unregistered, Registered, Liened, Stolen}
+}
+
+func (vr *TitleBrand) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeString(vr.Brand)
+	e.writeBytes(vr.Issuer)
+	e.writeInt64(vr.BrandDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *TitleBrand) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Brand = d.readString()
+	vr.Issuer = d.readBytes()
+	vr.BrandDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *TitleBrand) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *TitleBrand) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Issuer, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *TitleBrand) print_transaction() {
+	fmt.Println("Title Brand Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Brand: %s\n", vr.Brand)
+	fmt.Printf("Issuer: %x\n", vr.Issuer)
+	fmt.Printf("Brand Date: %s\n", time.Unix(vr.BrandDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *TitleBrand) jsonView() any {
+	return struct {
+		VIN       string   `json:"vin"`
+		Brand     string   `json:"brand"`
+		Issuer    HexBytes `json:"issuer"`
+		BrandDate ISODate  `json:"brandDate"`
+		Signature HexBytes `json:"signature"`
+	}{vr.VIN, vr.Brand, vr.Issuer, ISODate(vr.BrandDate), vr.Signature}
+}
+
+func (cli *CLI) addTitleBrand(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("TitleBrand", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	brand := cmd.String("brand", "", "Brand: salvage, rebuilt, flood or total-loss")
+	issuer := cmd.String("issuer", "", "Local identity of the DMV or insurer, who signs the brand")
+	date := cmd.String("date", "", "Brand date in YYYY-MM-DD format")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	if !validBrand(*brand) {
+		fmt.Println("Brand must be salvage, rebuilt, flood or total-loss.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if *issuer == "" {
+		fmt.Println("An issuer's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	brandDate, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid brand date format. Use YYYY-MM-DD.")
+	}
+
+	issuerID := cli.identity(*issuer)
+
+	tb := &TitleBrand{VIN: *vin, Brand: *brand, Issuer: issuerID.PublicKey, BrandDate: brandDate.Unix()}
+	tb.Sign(issuerID.PrivateKey)
+
+	// Role checks run against the chain and the pending pool
+	cli.submit(tb)
+
+	fmt.Println("Title brand transaction added to the pending pool!")
+}
+
+func validBrand(brand string) bool {
+	for _, b := range titleBrands {
+		if b == brand {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,38 +1,20 @@
 package main
 
 import (
-	"bytes"
-	"encoding/gob"
+	"context"
+	"crypto/ed25519"
 	"fmt"
 	"log"
 	"time"
 )
 
-type VehicleRegistration struct {
-	VIN              string
-	Owner            []byte
-	RegistrationDate int64
-}
-
-type VehicleSale struct {
-	VIN      string
-	Dealer   []byte
-	Buyer    []byte
-	SaleDate int64
-	Price    int
-}
-
-type LoanContract struct {
-	VIN        string
-	Borrower   []byte
-	Lender     []byte
-	LoanAmount int
-	StartDate  int64
-	EndDate    int64
+func init() {
+	RegisterTransactionType("genesis", func() Transaction { return &genesis{} }, nil)
 }
 
 type genesis struct {
-	VIN string
+	VIN       string
+	Authority []byte // Key allowed to authorize manufacturers; empty on chains created before authorities
 	//data string
 }
 
@@ -41,14 +23,26 @@ type Block struct {
 	Transaction_types []string
 	Transactions      []Transaction
 	PrevBlockHash     []byte
+	MerkleRoot        []byte
+	Height            int
+	TargetBits        int
 	Hash              []byte
 	Nonce             int
+
+	legacy bool // Decoded from the pre-canonical gob format
 }
 
+// Transaction is implemented by every record type stored on chain. Party
+// fields (Owner, Dealer, Buyer, Borrower, Lender, ...) hold ed25519 public
+// keys. New types register themselves with RegisterTransactionType.
 type Transaction interface {
-	ID() string        // Unique identifier for the transaction, typically the VIN for vehicle-related transactions.
-	Serialize() []byte // Converts the transaction data into a byte slice for hashing.
+	ID() string                    // Unique identifier for the transaction, typically the VIN for vehicle-related transactions.
+	Serialize() []byte             // Converts the transaction into its canonical encoding for hashing and storage.
+	Deserialize(data []byte) error // Restores the transaction from its canonical encoding.
+	Sign(privKey ed25519.PrivateKey)
+	Verify() bool // Checks every signature the transaction requires.
 	print_transaction()
+	jsonView() any // Structured form for JSON output, with keys and hashes in hex and dates as YYYY-MM-DD.
 }
 
 func (vr *genesis) ID() string {
@@ -56,94 +50,67 @@ func (vr *genesis) ID() string {
 }
 
 func (vr *genesis) Serialize() []byte {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-
-	if err := encoder.Encode(vr); err != nil {
-		log.Panic(err)
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	// Omitted when empty so genesis blocks from before authorities keep their hash
+	if len(vr.Authority) > 0 {
+		e.writeBytes(vr.Authority)
 	}
 
-	return result.Bytes()
+	return e.Bytes()
 }
 
-func (vr *genesis) print_transaction() {
-	fmt.Println("Genesis Block")
-	fmt.Printf("ID: %s\n", vr.VIN)
-}
+func (vr *genesis) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	if len(d.data) > 0 {
+		vr.Authority = d.readBytes()
+	}
 
-func (vr *VehicleRegistration) ID() string {
-	return vr.VIN
+	return d.finish()
 }
 
-func (vr *VehicleRegistration) Serialize() []byte {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
+func (vr *genesis) Sign(privKey ed25519.PrivateKey) {}
 
-	if err := encoder.Encode(vr); err != nil {
-		log.Panic(err)
-	}
-
-	return result.Bytes()
+func (vr *genesis) Verify() bool {
+	return true
 }
 
-func (vr *VehicleRegistration) print_transaction() {
-	fmt.Println("Vehicle Registration Transaction")
+func (vr *genesis) print_transaction() {
+	fmt.Println("Genesis Block")
 	fmt.Printf("ID: %s\n", vr.VIN)
-	fmt.Printf("Owner: %s\n", string(vr.Owner))                                                   // Convert byte slice to string
-	fmt.Printf("Registration Date: %s\n", time.Unix(vr.RegistrationDate, 0).Format("2006-01-02")) // Format Unix timestamp
+	if len(vr.Authority) > 0 {
+		fmt.Printf("Authority: %x\n", vr.Authority)
+	}
 }
 
-func (vr *VehicleSale) ID() string {
-	return vr.VIN
+func (vr *genesis) jsonView() any {
+	return struct {
+		VIN       string   `json:"vin"`
+		Authority HexBytes `json:"authority,omitempty"`
+	}{vr.VIN, vr.Authority}
 }
 
-func (vr *VehicleSale) Serialize() []byte {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-
-	if err := encoder.Encode(vr); err != nil {
-		log.Panic(err)
+// isChainRecord reports whether tx is about the chain itself, such as who may
+// do what, or about many vehicles at once like a recall, rather than about a
+// single vehicle. Its ID is not a VIN.
+func isChainRecord(tx Transaction) bool {
+	switch tx.(type) {
+	case *genesis, *ManufacturerAuthorization, *RoleGrant, *RecallNotice:
+		return true
 	}
 
-	return result.Bytes()
-}
-
-func (vr *VehicleSale) print_transaction() {
-	fmt.Println("Vehicle Sale Transaction")
-	fmt.Printf("ID: %s\n", vr.VIN)
-	fmt.Printf("Dealer: %s\n", string(vr.Dealer)) // Convert byte slice to string
-	fmt.Printf("Buyer: %s\n", string(vr.Buyer))   // Convert byte slice to string
-	fmt.Printf("Price: %d\n", vr.Price)
-	fmt.Printf("Sale Date: %s\n", time.Unix(vr.SaleDate, 0).Format("2006-01-02")) // Format Unix timestamp
-}
-
-func (vr *LoanContract) ID() string {
-	return vr.VIN
+	return false
 }
 
-func (vr *LoanContract) Serialize() []byte {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-
-	if err := encoder.Encode(vr); err != nil {
+func NewGenesisBlock(authority []byte) *Block {
+	gen_trans := &genesis{"GENESIS BLOCK", authority}
+	newBlock, err := NewBlock(context.Background(), []Transaction{gen_trans}, []byte{}, 0, time.Now().Unix(), initialTargetBits, nil)
+	if err != nil {
 		log.Panic(err)
 	}
-
-	return result.Bytes()
-}
-
-func (vr *LoanContract) print_transaction() {
-	fmt.Println("Loan Contract Transaction")
-	fmt.Printf("ID: %s\n", vr.VIN)
-	fmt.Printf("Lender: %s\n", string(vr.Lender))     // Convert byte slice to string
-	fmt.Printf("Borrower: %s\n", string(vr.Borrower)) // Convert byte slice to string
-	fmt.Printf("Loan Amount: %d\n", vr.LoanAmount)
-	fmt.Printf("Start Date: %s\n", time.Unix(vr.StartDate, 0).Format("2006-01-02")) // Format Unix timestamp
-	fmt.Printf("End Date: %s\n", time.Unix(vr.EndDate, 0).Format("2006-01-02"))     // Format Unix timestamp
-}
-
-func NewGenesisBlock() *Block {
-	gen_trans := &genesis{"GENESIS BLOCK"}
-	newBlock := NewBlock([]Transaction{gen_trans}, []byte{})
 	return newBlock
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/boltdb/bolt"
+	"log"
+)
+
+// txBucket maps the hash of every transaction on chain to the hash of the
+// block holding it, so a transaction cannot be recorded twice
+const txBucket = "transactions"
+
+// HasTransaction reports whether a transaction with the given hash is on chain
+func (bc *Blockchain) HasTransaction(hash []byte) bool {
+	var found bool
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket([]byte(txBucket)).Get(hash) != nil
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return found
+}
+
+// putTransaction records that the block with blockHash holds the transaction
+func putTransaction(tx *bolt.Tx, t Transaction, blockHash []byte) error {
+	return tx.Bucket([]byte(txBucket)).Put(TransactionHash(t), blockHash)
+}
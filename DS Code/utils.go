@@ -1,63 +1,21 @@
 package main
 
 import (
-	"errors"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 )
 
-func (bc *Blockchain) FindLatestOwnerByVIN(vin string) ([]byte, error) {
-	bci := bc.Iterator()
-	for {
-		block := bci.Next()
+// HexBytes marshals to JSON as a hex string rather than base64
+type HexBytes []byte
 
-		// Check transactions in reverse order since the latest transaction will be at the end
-		for i := len(block.Transactions) - 1; i >= 0; i-- {
-			tx := block.Transactions[i]
-			switch tx := tx.(type) {
-			case *VehicleRegistration:
-				if tx.VIN == vin {
-					return tx.Owner, nil
-				}
-			case *VehicleSale:
-				if tx.VIN == vin {
-					return tx.Buyer, nil
-				}
-			}
-		}
-
-		// Genesis block reached
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
-	}
-	return nil, errors.New("vehicle not found")
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
 }
 
-func (cli *CLI) hasActiveLoan(vin string) bool {
-	bci := cli.bc.Iterator()
-	currentTime := time.Now().Unix()
-
-	for {
-		block := bci.Next()
-
-		// Iterate through transactions in the block
-		for _, tx := range block.Transactions {
-			switch tx := tx.(type) {
-			case *LoanContract:
-				if tx.VIN == vin {
-					// Check if the loan end date is in the future, indicating an active loan
-					if tx.EndDate > currentTime {
-						return true
-					}
-				}
-			}
-		}
-
-		// Stop iteration if we've reached the genesis block
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
-	}
+// ISODate is a Unix timestamp that marshals to JSON as a YYYY-MM-DD date
+type ISODate int64
 
-	return false
+func (d ISODate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Unix(int64(d), 0).UTC().Format("2006-01-02"))
 }
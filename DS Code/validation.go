@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"Take1_Autochain/vin"
+)
+
+// ledgerView answers ownership and loan questions against the VIN index plus
+// a batch of transactions that have not been mined yet, so several actions on
+// the same VIN can be packed into one block.
+type ledgerView struct {
+	bc            *Blockchain // nil when replaying the chain from genesis
+	records       map[string]*VehicleRecord
+	authority     []byte // nil on chains without one, where registration stays open
+	manufacturers map[string]*Manufacturer
+	roles         map[string]bool // Granted in the batch, keyed like roleBucket
+	recalls       map[string]*RecallNotice
+	seen          map[string]bool // Hashes of the transactions in the batch
+	hasGenesis    bool            // A genesis record may only start the chain
+	now           int64           // When the batch is judged: its block's timestamp, or the present while pending
+}
+
+func newLedgerView(bc *Blockchain) *ledgerView {
+	v := &ledgerView{
+		bc:            bc,
+		records:       make(map[string]*VehicleRecord),
+		manufacturers: make(map[string]*Manufacturer),
+		roles:         make(map[string]bool),
+		recalls:       make(map[string]*RecallNotice),
+		seen:          make(map[string]bool),
+		now:           time.Now().Unix(),
+	}
+	if bc != nil {
+		v.authority = bc.Authority()
+		v.hasGenesis = true
+	}
+
+	return v
+}
+
+// record returns the working copy of a vehicle's record, loading it from the index on first use
+func (v *ledgerView) record(vin string) *VehicleRecord {
+	if record, ok := v.records[vin]; ok {
+		return record
+	}
+
+	var record *VehicleRecord
+	if v.bc != nil {
+		record = v.bc.FindVehicle(vin)
+	}
+	if record == nil {
+		record = &VehicleRecord{VIN: vin}
+	}
+	v.records[vin] = record
+
+	return record
+}
+
+// manufacturer returns the manufacturer authorized for wmi, or nil if there is none
+func (v *ledgerView) manufacturer(wmi string) *Manufacturer {
+	if m, ok := v.manufacturers[wmi]; ok {
+		return m
+	}
+
+	var m *Manufacturer
+	if v.bc != nil {
+		m = v.bc.FindManufacturer(wmi)
+	}
+	v.manufacturers[wmi] = m
+
+	return m
+}
+
+// hasRole reports whether key holds role on chain or through a grant in the batch
+func (v *ledgerView) hasRole(role string, key []byte) bool {
+	if role == roleDMV && v.authority != nil && bytes.Equal(key, v.authority) {
+		return true
+	}
+	if v.roles[string(roleKey(role, key))] {
+		return true
+	}
+
+	return v.bc != nil && v.bc.HasRole(role, key)
+}
+
+// lenderLien returns the active lien created by loan on vin, checking that lender holds it
+func (v *ledgerView) lenderLien(vin string, loan, lender []byte) (*Lien, error) {
+	lien := v.record(vin).lien(loan)
+	if lien == nil {
+		return nil, fmt.Errorf("no loan %x is recorded against the vehicle", loan)
+	}
+	if lien.Released {
+		return nil, errors.New("the lien has already been released")
+	}
+	if !bytes.Equal(lien.Contract.Lender, lender) {
+		return nil, errors.New("only the lender who holds the lien can sign for it")
+	}
+
+	return lien, nil
+}
+
+// checkClosing checks how a sale deals with liens on the vehicle. Without a
+// lender's consent the vehicle must be unencumbered; with it, the consenting
+// lender's lien must be the only active one, and any buyer's loan that takes
+// it over must be the buyer's own.
+func (v *ledgerView) checkClosing(tx *VehicleSale) error {
+	active := v.record(tx.VIN).activeLiens()
+	if len(tx.Lien) == 0 {
+		if tx.BuyerLoan != nil {
+			return errors.New("a buyer's loan can only take over a lien paid off at closing")
+		}
+		if len(active) > 0 {
+			return errors.New("the vehicle is currently under an active loan and cannot be sold without the lender's consent")
+		}
+		return nil
+	}
+
+	if _, err := v.lenderLien(tx.VIN, tx.Lien, tx.Lender); err != nil {
+		return err
+	}
+	if len(active) > 1 {
+		return errors.New("the vehicle has other active liens that must be released first")
+	}
+	if tx.Payoff <= 0 {
+		return errors.New("the payoff must be greater than 0")
+	}
+
+	if loan := tx.BuyerLoan; loan != nil {
+		if loan.VIN != tx.VIN || !bytes.Equal(loan.Borrower, tx.Buyer) {
+			return errors.New("the buyer's loan must be taken out by the buyer on this vehicle")
+		}
+		if err := loan.validateTerms(); err != nil {
+			return fmt.Errorf("buyer's loan: %w", err)
+		}
+		if v.record(tx.VIN).lien(TransactionHash(loan)) != nil {
+			return errors.New("the buyer's loan is already recorded against the vehicle")
+		}
+	}
+
+	return nil
+}
+
+// recall returns the notice that opened campaign, or nil if there is none
+func (v *ledgerView) recall(campaign string) *RecallNotice {
+	if notice, ok := v.recalls[campaign]; ok {
+		return notice
+	}
+
+	var notice *RecallNotice
+	if v.bc != nil {
+		notice = v.bc.FindRecall(campaign)
+	}
+	v.recalls[campaign] = notice
+
+	return notice
+}
+
+// check applies the business rules a transaction must satisfy before it can be mined
+func (v *ledgerView) check(tx Transaction) error {
+	if !tx.Verify() {
+		return errors.New("the transaction is not correctly signed by its parties")
+	}
+
+	// A signed transaction replayed as is would apply its effect twice. Each
+	// signing picks a fresh Nonce, so identical but separate transactions differ.
+	if hash := TransactionHash(tx); v.seen[string(hash)] || (v.bc != nil && v.bc.HasTransaction(hash)) {
+		return errors.New("the transaction is already on chain or pending")
+	}
+
+	if !isChainRecord(tx) {
+		if err := vin.Validate(tx.ID()); err != nil {
+			return fmt.Errorf("invalid VIN: %w", err)
+		}
+
+		vt, ok := tx.(vehicleTransaction)
+		if !ok {
+			return fmt.Errorf("%s does not declare the vehicle states it applies to", transactionType(tx))
+		}
+		if err := checkTransition(vt, v.record(tx.ID()).State()); err != nil {
+			return err
+		}
+	}
+
+	switch tx := tx.(type) {
+	case *genesis:
+		if v.hasGenesis {
+			return errors.New("a genesis record can only start the chain")
+		}
+	case *ManufacturerAuthorization:
+		if v.authority == nil {
+			return errors.New("this chain has no authority to authorize manufacturers")
+		}
+		if !bytes.Equal(tx.Authority, v.authority) {
+			return errors.New("the authorization is not signed by the chain authority")
+		}
+		if err := vin.ValidateWMI(tx.WMI); err != nil {
+			return err
+		}
+		if v.manufacturer(tx.WMI) != nil {
+			return fmt.Errorf("WMI %s is already authorized", tx.WMI)
+		}
+	case *RoleGrant:
+		if v.authority == nil {
+			return errors.New("this chain has no authority to grant roles")
+		}
+		if !bytes.Equal(tx.Authority, v.authority) {
+			return errors.New("the grant is not signed by the chain authority")
+		}
+		if !validRole(tx.Role) {
+			return fmt.Errorf("unknown role %q", tx.Role)
+		}
+		if v.hasRole(tx.Role, tx.Grantee) {
+			return fmt.Errorf("the grantee already holds the %s role", tx.Role)
+		}
+	case *RecallNotice:
+		if tx.Campaign == "" || tx.Description == "" {
+			return errors.New("a recall needs a campaign identifier and description")
+		}
+		if v.recall(tx.Campaign) != nil {
+			return fmt.Errorf("recall campaign %s is already on chain", tx.Campaign)
+		}
+		if len(tx.VINs) == 0 && len(tx.Ranges) == 0 {
+			return errors.New("the recall names no VINs")
+		}
+		for _, listed := range tx.VINs {
+			if err := vin.Validate(listed); err != nil {
+				return fmt.Errorf("invalid VIN %s: %w", listed, err)
+			}
+		}
+		for _, r := range tx.Ranges {
+			if err := r.validate(); err != nil {
+				return err
+			}
+		}
+		for _, wmi := range tx.wmis() {
+			if m := v.manufacturer(wmi); m == nil || !bytes.Equal(m.PublicKey, tx.Manufacturer) {
+				return fmt.Errorf("the signer is not the manufacturer authorized for WMI %s", wmi)
+			}
+		}
+	case *RecallRemedy:
+		notice := v.recall(tx.Campaign)
+		if notice == nil || !notice.affects(tx.VIN) {
+			return fmt.Errorf("recall campaign %s does not cover the vehicle", tx.Campaign)
+		}
+		if !v.hasRole(roleServiceStation, tx.Station) && !bytes.Equal(tx.Station, notice.Manufacturer) {
+			return errors.New("only an authorized service station or the recalling manufacturer can remedy a recall")
+		}
+		if v.record(tx.VIN).remedied(tx.Campaign) {
+			return fmt.Errorf("recall %s has already been remedied on the vehicle", tx.Campaign)
+		}
+	case *TitleBrand:
+		if !v.hasRole(roleDMV, tx.Issuer) && !v.hasRole(roleInsurer, tx.Issuer) {
+			return errors.New("only a DMV or an insurer can brand a title")
+		}
+		if !validBrand(tx.Brand) {
+			return fmt.Errorf("unknown title brand %q", tx.Brand)
+		}
+		record := v.record(tx.VIN)
+		if record.hasBrand(tx.Brand) {
+			return fmt.Errorf("the title is already branded %s", tx.Brand)
+		}
+		if tx.Brand == "rebuilt" && !record.hasBrand("salvage") && !record.hasBrand("total-loss") {
+			return errors.New("only a salvage or total-loss vehicle can be branded rebuilt")
+		}
+	case *ManufacturerIssue:
+		m := v.manufacturer(tx.VIN[:3])
+		if m == nil {
+			return fmt.Errorf("no manufacturer is authorized for WMI %s", tx.VIN[:3])
+		}
+		if !bytes.Equal(m.PublicKey, tx.Manufacturer) {
+			return fmt.Errorf("the signer is not the manufacturer authorized for WMI %s", tx.VIN[:3])
+		}
+	case *VehicleRegistration:
+		record := v.record(tx.VIN)
+		if record.Owner == nil {
+			// Chains without an authority keep the original open registration
+			// for VINs not on chain yet, which makes the registrant the owner
+			if v.authority == nil {
+				break
+			}
+			return errors.New("the VIN has not been issued by its manufacturer")
+		}
+		if !bytes.Equal(record.Owner, tx.Owner) {
+			return errors.New("only the current owner can register the vehicle")
+		}
+		if record.Registered {
+			return errors.New("the vehicle is already registered to its current owner")
+		}
+	case *VehicleSale:
+		record := v.record(tx.VIN)
+		if !bytes.Equal(record.Owner, tx.Dealer) {
+			return errors.New("the dealer is not the current owner of the vehicle")
+		}
+		if brands := record.Brands; len(brands) > 0 && !tx.BrandDisclosed {
+			return fmt.Errorf("the title is branded %s; the sale must disclose it", strings.Join(brands, ", "))
+		}
+		if err := v.checkClosing(tx); err != nil {
+			return err
+		}
+	case *LoanContract:
+		if v.record(tx.VIN).lien(TransactionHash(tx)) != nil {
+			return errors.New("the loan is already recorded against the vehicle")
+		}
+		if !bytes.Equal(v.record(tx.VIN).Owner, tx.Borrower) {
+			return errors.New("the borrower is not the current owner of the vehicle")
+		}
+		if err := tx.validateTerms(); err != nil {
+			return err
+		}
+	case *LoanPayment:
+		lien, err := v.lenderLien(tx.VIN, tx.Loan, tx.Lender)
+		if err != nil {
+			return err
+		}
+		if tx.Amount <= 0 {
+			return errors.New("a payment must be greater than 0")
+		}
+		if tx.PaymentDate < lien.Contract.StartDate {
+			return errors.New("the payment is dated before the loan starts")
+		}
+		if tx.PaymentDate > v.now {
+			return errors.New("the payment is dated in the future")
+		}
+		if owed := lien.Outstanding(tx.PaymentDate); tx.Amount > owed {
+			return fmt.Errorf("the payment of %d exceeds the outstanding balance of %d", tx.Amount, owed)
+		}
+	case *LienRelease:
+		if _, err := v.lenderLien(tx.VIN, tx.Loan, tx.Lender); err != nil {
+			return err
+		}
+	case *InsurancePolicy:
+		if !v.hasRole(roleInsurer, tx.Insurer) {
+			return errors.New("only a registered insurer can write a policy")
+		}
+		if tx.PolicyID == "" {
+			return errors.New("a policy number is required")
+		}
+		if tx.EndDate <= tx.StartDate {
+			return errors.New("the policy must end after it starts")
+		}
+		if v.record(tx.VIN).policy(tx.PolicyID) != nil {
+			return fmt.Errorf("policy %s is already recorded for the vehicle", tx.PolicyID)
+		}
+	case *InsuranceClaim:
+		if !v.hasRole(roleInsurer, tx.Insurer) {
+			return errors.New("only a registered insurer can settle a claim")
+		}
+		policy := v.record(tx.VIN).policy(tx.PolicyID)
+		if policy == nil {
+			return fmt.Errorf("policy %s is not recorded for the vehicle", tx.PolicyID)
+		}
+		if !bytes.Equal(policy.Insurer, tx.Insurer) {
+			return errors.New("only the insurer who wrote the policy can settle claims on it")
+		}
+		if !policy.covers(tx.IncidentDate) {
+			return errors.New("the incident falls outside the policy's coverage period")
+		}
+		if !validSeverity(tx.Severity) {
+			return fmt.Errorf("unknown damage severity %q", tx.Severity)
+		}
+		if tx.Payout < 0 {
+			return errors.New("the payout cannot be negative")
+		}
+	case *Deregistration:
+		if !bytes.Equal(v.record(tx.VIN).Owner, tx.Issuer) && !v.hasRole(roleDMV, tx.Issuer) {
+			return errors.New("only the owner or a DMV can deregister the vehicle")
+		}
+		if !validDeregistrationReason(tx.Reason) {
+			return fmt.Errorf("unknown deregistration reason %q", tx.Reason)
+		}
+	case *TheftReport:
+		if !v.hasRole(roleLawEnforcement, tx.Issuer) && !v.hasRole(roleDMV, tx.Issuer) {
+			return errors.New("only law enforcement or a DMV can report a theft")
+		}
+		if tx.CaseNumber == "" {
+			return errors.New("a case number is required")
+		}
+	case *TheftRecovery:
+		if !v.hasRole(roleLawEnforcement, tx.Issuer) && !v.hasRole(roleDMV, tx.Issuer) {
+			return errors.New("only law enforcement or a DMV can record a recovery")
+		}
+		if open := v.record(tx.VIN).Theft.CaseNumber; tx.CaseNumber != open {
+			return fmt.Errorf("the open theft report is case %s, not %s", open, tx.CaseNumber)
+		}
+	case *ServiceRecord:
+		last := v.record(tx.VIN).LastOdometer
+		if tx.Odometer < last && !tx.OdometerReplaced {
+			return fmt.Errorf("odometer reading %d is lower than the last recorded %d; possible rollback", tx.Odometer, last)
+		}
+	}
+
+	return nil
+}
+
+// apply records the effect of an accepted transaction on the view
+func (v *ledgerView) apply(tx Transaction) {
+	v.seen[string(TransactionHash(tx))] = true
+
+	switch tx := tx.(type) {
+	case *genesis:
+		v.authority = tx.Authority
+		v.hasGenesis = true
+	case *ManufacturerAuthorization:
+		v.manufacturers[tx.WMI] = &Manufacturer{WMI: tx.WMI, Name: tx.Name, PublicKey: tx.Manufacturer}
+	case *RoleGrant:
+		v.roles[string(roleKey(tx.Role, tx.Grantee))] = true
+	case *RecallNotice:
+		v.recalls[tx.Campaign] = tx
+	default:
+		v.record(tx.ID()).apply(tx)
+	}
+}
+
+// ValidateBlock checks a block received from a peer before it is stored: it
+// must extend our tip, carry the hash of its own header, a plausible
+// timestamp and a valid proof of work at the difficulty the chain dictates
+// for its height, hold no transaction twice, and every transaction must be
+// correctly signed and pass the same rules as the mempool.
+func (bc *Blockchain) ValidateBlock(block *Block) error {
+	tip := bc.lastHash()
+	if !bytes.Equal(block.PrevBlockHash, tip) {
+		return errors.New("block does not extend the current tip")
+	}
+
+	// The hash is the block's key in the database, so it must be the header's own
+	if hash := sha256.Sum256(block.prepareData()); !bytes.Equal(hash[:], block.Hash) {
+		return fmt.Errorf("block hash does not match its header, which hashes to %x", hash)
+	}
+
+	window := bc.retargetWindow(tip)
+	if block.Height != window[len(window)-1].Height+1 {
+		return fmt.Errorf("block height %d does not follow the tip", block.Height)
+	}
+
+	if err := checkTimestamp(window, block.Timestamp, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	if !NewProofOfWork(block).Validate(nextTargetBits(window)) {
+		return errors.New("invalid proof of work")
+	}
+
+	if block.HasDuplicateTransactions() {
+		return errors.New("block contains the same transaction twice")
+	}
+
+	view := newLedgerView(bc)
+	view.now = block.Timestamp
+	for i, tx := range block.Transactions {
+		if err := view.check(tx); err != nil {
+			return fmt.Errorf("transaction %d (%s %s): %w", i, transactionType(tx), tx.ID(), err)
+		}
+		view.apply(tx)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("VehicleRegistration", func() Transaction { return &VehicleRegistration{} }, &CLISpec{
+		Usage: "-vin VIN -owner OWNER -date DATE",
+		Run:   (*CLI).addVehicleRegistration,
+	})
+}
+
+type VehicleRegistration struct {
+	Nonce
+	VIN              string
+	Owner            []byte
+	RegistrationDate int64
+	Signature        []byte // By Owner
+}
+
+func (vr *VehicleRegistration) ID() string {
+	return vr.VIN
+}
+
+// Chains without an authority register VINs that are not on chain yet;
+// otherwise only the current owner registers, after a sale, liened or not
+func (vr *VehicleRegistration) SourceStates() []VehicleState {
+	return []VehicleState{Unissued, Unregistered, Liened}
+}
+
+func (vr *VehicleRegistration) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Owner)
+	e.writeInt64(vr.RegistrationDate)
+	e.writeBytes(vr.Signature)
+
+	return e.Bytes()
+}
+
+func (vr *VehicleRegistration) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Owner = d.readBytes()
+	vr.RegistrationDate = d.readInt64()
+	vr.Signature = d.readBytes()
+
+	return d.finish()
+}
+
+func (vr *VehicleRegistration) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	txCopy := *vr
+	txCopy.Signature = nil
+	vr.Signature = ed25519.Sign(privKey, signaturePayload(&txCopy))
+}
+
+func (vr *VehicleRegistration) Verify() bool {
+	txCopy := *vr
+	txCopy.Signature = nil
+	return verifySignature(vr.Owner, signaturePayload(&txCopy), vr.Signature)
+}
+
+func (vr *VehicleRegistration) print_transaction() {
+	fmt.Println("Vehicle Registration Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Owner: %x\n", vr.Owner)
+	fmt.Printf("Registration Date: %s\n", time.Unix(vr.RegistrationDate, 0).Format("2006-01-02")) // Format Unix timestamp
+}
+
+func (vr *VehicleRegistration) jsonView() any {
+	return struct {
+		VIN              string   `json:"vin"`
+		Owner            HexBytes `json:"owner"`
+		RegistrationDate ISODate  `json:"registrationDate"`
+		Signature        HexBytes `json:"signature"`
+	}{vr.VIN, vr.Owner, ISODate(vr.RegistrationDate), vr.Signature}
+}
+
+func (cli *CLI) addVehicleRegistration(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("VehicleRegistration", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	owner := cmd.String("owner", "", "Owner's local identity, who signs the registration")
+	date := cmd.String("date", "", "Registration date in YYYY-MM-DD format")
+
+	// Parse the provided arguments according to the defined flags
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	// Validate owner
+	if *owner == "" {
+		fmt.Println("An owner's identifier is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	// Validate registration date
+	date_validated, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid start date format. Use YYYY-MM-DD.")
+	}
+
+	ownerID := cli.identity(*owner)
+
+	// Create and sign the VehicleRegistration transaction
+	vr := &VehicleRegistration{VIN: *vin, Owner: ownerID.PublicKey, RegistrationDate: date_validated.Unix()}
+	vr.Sign(ownerID.PrivateKey)
+
+	// Queue the transaction for the next mined block
+	cli.submit(vr)
+
+	fmt.Println("Vehicle registration transaction added to the pending pool!")
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterTransactionType("VehicleSale", func() Transaction { return &VehicleSale{} }, &CLISpec{
+		Usage: "-vin VIN -dealer DEALER -buyer BUYER -date DATE -price PRICE [-disclose-brand] [-block-open-recalls] [-lender LENDER [-payoff AMOUNT] [-loan HASH] [-buyer-lender LENDER -buyer-loan-amount AMOUNT -buyer-installments N [-buyer-apr PERCENT] [-buyer-frequency FREQUENCY]]]",
+		Run:   (*CLI).addVehicleSale,
+	})
+}
+
+type VehicleSale struct {
+	Nonce
+	VIN       string
+	Dealer    []byte
+	Buyer     []byte
+	SaleDate  int64
+	Price     int
+	Signature []byte // By Dealer, who must be the current owner
+
+	// Closing of a financed vehicle, empty otherwise. The lender co-signs to
+	// release its lien for the payoff; if the buyer finances the purchase, the
+	// buyer's loan takes the lien over in the same transaction.
+	Lien            []byte // Hash of the loan paid off at closing
+	Lender          []byte
+	Payoff          int
+	BuyerLoan       *LoanContract // Signed by the buyer and the buyer's lender
+	LenderSignature []byte
+
+	BrandDisclosed bool // The buyer was told of the title's brands
+}
+
+func (vr *VehicleSale) ID() string {
+	return vr.VIN
+}
+
+func (vr *VehicleSale) SourceStates() []VehicleState {
+	return activeStates
+}
+
+func (vr *VehicleSale) Serialize() []byte {
+	e := newTransactionEncoder(vr)
+	e.writeString(vr.VIN)
+	e.writeBytes(vr.Dealer)
+	e.writeBytes(vr.Buyer)
+	e.writeInt64(vr.SaleDate)
+	e.writeInt(vr.Price)
+	e.writeBytes(vr.Signature)
+	// Later fields are left out when unused so earlier sales keep their hash
+	if len(vr.Lien) > 0 || vr.BrandDisclosed {
+		e.writeBytes(vr.Lien)
+		e.writeBytes(vr.Lender)
+		e.writeInt(vr.Payoff)
+		if vr.BuyerLoan != nil {
+			e.writeBytes(vr.BuyerLoan.Serialize())
+		} else {
+			e.writeBytes(nil)
+		}
+		e.writeBytes(vr.LenderSignature)
+	}
+	if vr.BrandDisclosed {
+		e.writeBool(vr.BrandDisclosed)
+	}
+
+	return e.Bytes()
+}
+
+func (vr *VehicleSale) Deserialize(data []byte) error {
+	d, err := newTransactionDecoder(data, vr)
+	if err != nil {
+		return err
+	}
+	vr.VIN = d.readString()
+	vr.Dealer = d.readBytes()
+	vr.Buyer = d.readBytes()
+	vr.SaleDate = d.readInt64()
+	vr.Price = d.readInt()
+	vr.Signature = d.readBytes()
+	if len(d.data) > 0 {
+		vr.Lien = d.readBytes()
+		vr.Lender = d.readBytes()
+		vr.Payoff = d.readInt()
+		if loan := d.readBytes(); loan != nil {
+			vr.BuyerLoan = &LoanContract{}
+			if err := vr.BuyerLoan.Deserialize(loan); err != nil {
+				return fmt.Errorf("buyer's loan: %w", err)
+			}
+		}
+		vr.LenderSignature = d.readBytes()
+	}
+	if len(d.data) > 0 {
+		vr.BrandDisclosed = d.readBool()
+	}
+
+	return d.finish()
+}
+
+// Sign adds the dealer's signature. The dealer and the lender sign the same
+// payload, which excludes both signatures.
+func (vr *VehicleSale) Sign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	vr.Signature = ed25519.Sign(privKey, vr.signingData())
+}
+
+// CoSign adds the consent of the lender whose lien is paid off at closing
+func (vr *VehicleSale) CoSign(privKey ed25519.PrivateKey) {
+	vr.Nonce.ensure()
+	vr.LenderSignature = ed25519.Sign(privKey, vr.signingData())
+}
+
+func (vr *VehicleSale) Verify() bool {
+	payload := vr.signingData()
+	if !verifySignature(vr.Dealer, payload, vr.Signature) {
+		return false
+	}
+	if len(vr.Lien) > 0 && !verifySignature(vr.Lender, payload, vr.LenderSignature) {
+		return false
+	}
+
+	return vr.BuyerLoan == nil || vr.BuyerLoan.Verify()
+}
+
+func (vr *VehicleSale) signingData() []byte {
+	txCopy := *vr
+	txCopy.Signature = nil
+	txCopy.LenderSignature = nil
+	return signaturePayload(&txCopy)
+}
+
+func (vr *VehicleSale) print_transaction() {
+	fmt.Println("Vehicle Sale Transaction")
+	fmt.Printf("ID: %s\n", vr.VIN)
+	fmt.Printf("Dealer: %x\n", vr.Dealer)
+	fmt.Printf("Buyer: %x\n", vr.Buyer)
+	fmt.Printf("Price: %d\n", vr.Price)
+	fmt.Printf("Sale Date: %s\n", time.Unix(vr.SaleDate, 0).Format("2006-01-02")) // Format Unix timestamp
+	if vr.BrandDisclosed {
+		fmt.Println("Title Brand Disclosed: yes")
+	}
+	if len(vr.Lien) > 0 {
+		fmt.Printf("Lien Paid Off: %x\n", vr.Lien)
+		fmt.Printf("Lender: %x\n", vr.Lender)
+		fmt.Printf("Payoff: %d\n", vr.Payoff)
+	}
+	if vr.BuyerLoan != nil {
+		fmt.Printf("Lien Transferred To: %x\n", TransactionHash(vr.BuyerLoan))
+		fmt.Printf("Buyer's Lender: %x\n", vr.BuyerLoan.Lender)
+		fmt.Printf("Buyer's Loan Amount: %d\n", vr.BuyerLoan.LoanAmount)
+	}
+}
+
+func (vr *VehicleSale) jsonView() any {
+	var buyerLoan any
+	if vr.BuyerLoan != nil {
+		buyerLoan = vr.BuyerLoan.jsonView()
+	}
+
+	return struct {
+		VIN             string   `json:"vin"`
+		Dealer          HexBytes `json:"dealer"`
+		Buyer           HexBytes `json:"buyer"`
+		SaleDate        ISODate  `json:"saleDate"`
+		Price           int      `json:"price"`
+		Signature       HexBytes `json:"signature"`
+		Lien            HexBytes `json:"lien,omitempty"`
+		Lender          HexBytes `json:"lender,omitempty"`
+		Payoff          int      `json:"payoff,omitempty"`
+		BuyerLoan       any      `json:"buyerLoan,omitempty"`
+		LenderSignature HexBytes `json:"lenderSignature,omitempty"`
+		BrandDisclosed  bool     `json:"brandDisclosed,omitempty"`
+	}{vr.VIN, vr.Dealer, vr.Buyer, ISODate(vr.SaleDate), vr.Price, vr.Signature,
+		vr.Lien, vr.Lender, vr.Payoff, buyerLoan, vr.LenderSignature, vr.BrandDisclosed}
+}
+
+func (cli *CLI) addVehicleSale(args []string) {
+	layout := "2006-01-02"
+	cmd := flag.NewFlagSet("VehicleSale", flag.ExitOnError)
+	vin := cmd.String("vin", "", "Vehicle Identification Number")
+	dealer := cmd.String("dealer", "", "Dealer's local identity, who signs the sale")
+	buyer := cmd.String("buyer", "", "Buyer's identity name or hex public key")
+	date := cmd.String("date", "", "Sale date in YYYY-MM-DD format")
+	price := cmd.Int("price", 0, "Sale price")
+	discloseBrand := cmd.Bool("disclose-brand", false, "Confirm the buyer was told of the title's brands, required to sell a branded vehicle")
+	blockOpenRecalls := cmd.Bool("block-open-recalls", false, "Refuse the sale while recalls are open on the vehicle instead of only warning")
+	lender := cmd.String("lender", "", "Local identity of the lender whose lien is paid off at closing, who co-signs")
+	payoff := cmd.Int("payoff", 0, "Amount paid to the lender at closing; defaults to the outstanding balance")
+	loanHash := cmd.String("loan", "", "Hash of the loan paid off, needed when the lender holds several liens on the vehicle")
+	buyerLender := cmd.String("buyer-lender", "", "Local identity of the buyer's lender, whose new loan takes the lien over")
+	buyerLoanAmount := cmd.Int("buyer-loan-amount", 0, "Amount of the buyer's loan")
+	buyerAPR := cmd.Float64("buyer-apr", 0, "Annual percentage rate of the buyer's loan, e.g. 5.99")
+	buyerInstallments := cmd.Int("buyer-installments", 0, "Number of payments on the buyer's loan")
+	buyerFrequency := cmd.String("buyer-frequency", "monthly", "Payment frequency of the buyer's loan: monthly, biweekly or weekly")
+
+	err := cmd.Parse(args)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Validate VIN
+	if *vin == "" {
+		fmt.Println("A valid VIN is required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+	cli.checkVIN(*vin)
+
+	// Validate dealer and buyer
+	if *dealer == "" || *buyer == "" {
+		fmt.Println("Both dealer and buyer identifiers are required.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	// Validate sale price
+	if *price <= 0 {
+		fmt.Println("Sale price must be greater than 0.")
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	date_validated, err := time.Parse(layout, *date)
+	if err != nil {
+		log.Panic("Invalid start date format. Use YYYY-MM-DD.")
+	}
+
+	// Dealers must check for open recalls before a sale
+	if open, _ := cli.bc.Recalls(*vin); len(open) > 0 {
+		fmt.Printf("Warning: VIN %s has %d open recalls:\n", *vin, len(open))
+		for _, rn := range open {
+			fmt.Printf("  %s: %s\n", rn.Campaign, rn.Description)
+		}
+		if *blockOpenRecalls {
+			fmt.Println("Error: the sale is blocked until the recalls are remedied.")
+			os.Exit(1)
+		}
+	}
+
+	dealerID := cli.identity(*dealer)
+
+	// Create and sign the VehicleSale transaction
+	vs := &VehicleSale{
+		VIN:            *vin,
+		Dealer:         dealerID.PublicKey,
+		Buyer:          cli.party(*buyer),
+		SaleDate:       date_validated.Unix(),
+		Price:          *price,
+		BrandDisclosed: *discloseBrand,
+	}
+
+	if *lender != "" {
+		lenderID := cli.identity(*lender)
+		vs.Lien = cli.lenderLoan(*vin, lenderID.PublicKey, *loanHash)
+		vs.Lender = lenderID.PublicKey
+		vs.Payoff = *payoff
+		if record := cli.bc.FindVehicle(*vin); record != nil && vs.Payoff == 0 {
+			if lien := record.lien(vs.Lien); lien != nil {
+				vs.Payoff = lien.Outstanding(vs.SaleDate)
+			}
+		}
+
+		if *buyerLender != "" {
+			if *buyerLoanAmount <= 0 || *buyerInstallments <= 0 {
+				fmt.Println("The buyer's loan needs an amount and a number of installments.")
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			// The buyer signs the new loan, so must be a local identity here
+			buyerID := cli.identity(*buyer)
+			buyerLenderID := cli.identity(*buyerLender)
+			lc := &LoanContract{
+				VIN:              *vin,
+				Borrower:         buyerID.PublicKey,
+				Lender:           buyerLenderID.PublicKey,
+				LoanAmount:       *buyerLoanAmount,
+				StartDate:        vs.SaleDate,
+				APR:              int(math.Round(*buyerAPR * 100)),
+				PaymentFrequency: *buyerFrequency,
+				Installments:     *buyerInstallments,
+			}
+			lc.EndDate = lc.dueDate(lc.Installments).Unix()
+			lc.Sign(buyerID.PrivateKey)
+			lc.CoSign(buyerLenderID.PrivateKey)
+			vs.BuyerLoan = lc
+		}
+		vs.CoSign(lenderID.PrivateKey)
+	} else if *buyerLender != "" {
+		fmt.Println("A buyer's loan can only take over a lien; finance an unencumbered vehicle with a LoanContract.")
+		os.Exit(1)
+	}
+	vs.Sign(dealerID.PrivateKey)
+
+	// Ownership and loan checks run against the chain and the pending pool
+	cli.submit(vs)
+
+	fmt.Println("Vehicle sale transaction added to the pending pool!")
+}
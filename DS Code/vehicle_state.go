@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VehicleState is where a VIN's title stands, derived from its record. Each
+// vehicle transaction declares the states it may be recorded in, and the
+// validator rejects any other.
+type VehicleState int
+
+const (
+	Unissued     VehicleState = iota // Nothing recorded for the VIN yet
+	Unregistered                     // Owned, but the current owner has not registered it
+	Registered                       // Registered to its current owner
+	Liened                           // A loan secured on the vehicle has not been released
+	Stolen                           // Reported stolen and not recovered
+	Deregistered                     // Scrapped, exported or destroyed; nothing more can be recorded
+)
+
+var vehicleStateNames = []string{"unissued", "unregistered", "registered", "liened", "stolen", "deregistered"}
+
+func (s VehicleState) String() string {
+	if s < 0 || int(s) >= len(vehicleStateNames) {
+		return fmt.Sprintf("VehicleState(%d)", int(s))
+	}
+
+	return vehicleStateNames[s]
+}
+
+// activeStates are those of a vehicle in normal use
+var activeStates = []VehicleState{Unregistered, Registered, Liened}
+
+// vehicleTransaction is implemented by every transaction about a vehicle,
+// that is every one except the chain records
+type vehicleTransaction interface {
+	Transaction
+	SourceStates() []VehicleState // States the vehicle may be in for the transaction to be recorded
+}
+
+// State returns where the vehicle's title stands. A vehicle in more than one
+// state, such as liened and stolen, reports the most restrictive.
+func (r *VehicleRecord) State() VehicleState {
+	switch {
+	case r.Owner == nil:
+		return Unissued
+	case r.Deregistered != "":
+		return Deregistered
+	case r.Theft != nil:
+		return Stolen
+	case len(r.activeLiens()) > 0:
+		return Liened
+	case r.Registered:
+		return Registered
+	default:
+		return Unregistered
+	}
+}
+
+// checkTransition rejects a transaction the vehicle's current state does not allow
+func checkTransition(tx vehicleTransaction, state VehicleState) error {
+	allowed := tx.SourceStates()
+	for _, s := range allowed {
+		if s == state {
+			return nil
+		}
+	}
+
+	names := make([]string, len(allowed))
+	for i, s := range allowed {
+		names[i] = s.String()
+	}
+	expected := names[len(names)-1]
+	if len(names) > 1 {
+		expected = strings.Join(names[:len(names)-1], ", ") + " or " + expected
+	}
+
+	return fmt.Errorf("a %s cannot be recorded while the vehicle is %s; it must be %s",
+		transactionType(tx), state, expected)
+}
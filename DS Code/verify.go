@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChainError identifies the first block, and transaction if any, that fails verification
+type ChainError struct {
+	Height    int
+	BlockHash []byte
+	TxIndex   int // -1 when the block itself is at fault
+	TxType    string
+	VIN       string
+	Err       error
+}
+
+func (e *ChainError) Error() string {
+	if e.TxIndex < 0 {
+		return fmt.Sprintf("block %d (%x): %v", e.Height, e.BlockHash, e.Err)
+	}
+
+	return fmt.Sprintf("block %d (%x), transaction %d (%s %s): %v",
+		e.Height, e.BlockHash, e.TxIndex, e.TxType, e.VIN, e.Err)
+}
+
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyChain replays the chain from genesis and returns the number of blocks
+// checked. It stops at the first block whose linkage, hash, timestamp,
+// difficulty or proof of work is wrong, or whose transactions break a signature or business
+// rule as judged at the time the block was mined.
+func (bc *Blockchain) VerifyChain() (int, error) {
+	blocks, err := bc.BlocksFromGenesis()
+	if err != nil {
+		return 0, err
+	}
+
+	view := newLedgerView(nil)
+	var prevHash []byte
+	now := time.Now().Unix()
+
+	for height, block := range blocks {
+		blockErr := func(err error) error {
+			return &ChainError{Height: height, BlockHash: block.Hash, TxIndex: -1, Err: err}
+		}
+
+		if !bytes.Equal(block.PrevBlockHash, prevHash) {
+			return height, blockErr(fmt.Errorf("previous hash %x does not match block %d (%x)", block.PrevBlockHash, height-1, prevHash))
+		}
+
+		hash := sha256.Sum256(block.prepareData())
+		if !bytes.Equal(hash[:], block.Hash) {
+			return height, blockErr(fmt.Errorf("stored hash does not match recomputed hash %x", hash))
+		}
+
+		if !bytes.Equal(block.MerkleRoot, block.HashTransactions()) {
+			return height, blockErr(errors.New("Merkle root does not match the block's transactions"))
+		}
+
+		if block.HasDuplicateTransactions() {
+			return height, blockErr(errors.New("block contains the same transaction twice"))
+		}
+
+		if block.Height != height {
+			return height, blockErr(fmt.Errorf("header claims height %d", block.Height))
+		}
+
+		window := blocks[max(0, height-retargetInterval):height]
+		if err := checkTimestamp(window, block.Timestamp, now); err != nil {
+			return height, blockErr(err)
+		}
+
+		expectedBits := nextTargetBits(window)
+		if block.TargetBits != expectedBits {
+			return height, blockErr(fmt.Errorf("target bits %d, but the chain requires %d at this height", block.TargetBits, expectedBits))
+		}
+
+		if !NewProofOfWork(block).Validate(expectedBits) {
+			return height, blockErr(errors.New("hash does not meet the proof-of-work target"))
+		}
+
+		view.now = block.Timestamp
+		for i, tx := range block.Transactions {
+			if err := view.check(tx); err != nil {
+				return height, &ChainError{height, block.Hash, i, transactionType(tx), tx.ID(), err}
+			}
+			view.apply(tx)
+		}
+
+		prevHash = block.Hash
+	}
+
+	return len(blocks), nil
+}
@@ -0,0 +1,138 @@
+// Package vin validates and decodes 17-character Vehicle Identification
+// Numbers as laid out by ISO 3779, with the North American check digit in
+// position 9 and the model year code in position 10.
+package vin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Length is the number of characters in a VIN
+const Length = 17
+
+// Info is what can be read from a VIN without a manufacturer's tables
+type Info struct {
+	VIN        string `json:"vin"`
+	WMI        string `json:"wmi"`        // World Manufacturer Identifier, positions 1-3
+	VDS        string `json:"vds"`        // Vehicle Descriptor Section, positions 4-8
+	CheckDigit string `json:"checkDigit"` // Position 9
+	ModelYear  int    `json:"modelYear"`  // Decoded from position 10
+	Plant      string `json:"plant"`      // Assembly plant code, position 11
+	Serial     string `json:"serial"`     // Production sequence number, positions 12-17
+}
+
+func (i *Info) String() string {
+	return fmt.Sprintf("WMI %s, model year %d, plant %s, serial %s", i.WMI, i.ModelYear, i.Plant, i.Serial)
+}
+
+// transliteration gives each allowed letter its check digit value. I, O and
+// Q are left out because they are easily confused with 1 and 0.
+var transliteration = map[byte]int{
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+}
+
+var weights = [Length]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// yearCodes is the position-10 code sequence; it repeats every 30 years
+const yearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// value returns the check digit value of c, or false if c may not appear in a VIN
+func value(c byte) (int, bool) {
+	if c >= '0' && c <= '9' {
+		return int(c - '0'), true
+	}
+
+	v, ok := transliteration[c]
+	return v, ok
+}
+
+// CheckDigit computes the check digit for the 17 characters of v; the
+// character already in position 9 is ignored
+func CheckDigit(v string) (byte, error) {
+	if len(v) != Length {
+		return 0, fmt.Errorf("a VIN is %d characters, not %d", Length, len(v))
+	}
+
+	sum := 0
+	for i := 0; i < Length; i++ {
+		n, ok := value(v[i])
+		if !ok {
+			return 0, fmt.Errorf("character %q at position %d is not allowed in a VIN", v[i], i+1)
+		}
+		sum += n * weights[i]
+	}
+
+	if r := sum % 11; r != 10 {
+		return byte('0' + r), nil
+	}
+	return 'X', nil
+}
+
+// ValidateWMI checks that wmi could start a VIN
+func ValidateWMI(wmi string) error {
+	if len(wmi) != 3 {
+		return errors.New("a WMI is exactly three characters")
+	}
+	for i := 0; i < len(wmi); i++ {
+		if _, ok := value(wmi[i]); !ok {
+			return fmt.Errorf("character %q is not allowed in a WMI", wmi[i])
+		}
+	}
+
+	return nil
+}
+
+// Validate checks length, allowed characters, the model year code and the check digit
+func Validate(v string) error {
+	_, err := Decode(v)
+	return err
+}
+
+// Decode validates v and splits it into its sections
+func Decode(v string) (*Info, error) {
+	want, err := CheckDigit(v)
+	if err != nil {
+		return nil, err
+	}
+	if v[8] != want {
+		return nil, fmt.Errorf("check digit is %c but should be %c", v[8], want)
+	}
+
+	year, err := modelYear(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Info{
+		VIN:        v,
+		WMI:        v[0:3],
+		VDS:        v[3:8],
+		CheckDigit: v[8:9],
+		ModelYear:  year,
+		Plant:      v[10:11],
+		Serial:     v[11:17],
+	}, nil
+}
+
+// modelYear decodes position 10. The code alone is ambiguous across 30-year
+// cycles; for light vehicles a digit in position 7 means 1980-2009 and a
+// letter means 2010-2039.
+func modelYear(v string) (int, error) {
+	code := -1
+	for i := 0; i < len(yearCodes); i++ {
+		if yearCodes[i] == v[9] {
+			code = i
+		}
+	}
+	if code < 0 {
+		return 0, fmt.Errorf("%q is not a model year code", v[9])
+	}
+
+	if v[6] >= '0' && v[6] <= '9' {
+		return 1980 + code, nil
+	}
+	return 2010 + code, nil
+}
@@ -0,0 +1,89 @@
+package vin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		vin  string
+		year int
+	}{
+		{"1HGCM82633A004352", 2003},
+		{"1M8GDM9AXKP042788", 1989}, // Check digit X
+		{"JH4KA7561PC008269", 1993},
+		{"11111111111111111", 2001},
+		{"5YJ3E1EA2KF317000", 2019}, // Letter in position 7 selects the 2010 cycle
+	}
+
+	for _, tt := range tests {
+		info, err := Decode(tt.vin)
+		if err != nil {
+			t.Errorf("Decode(%s): %v", tt.vin, err)
+			continue
+		}
+		if info.ModelYear != tt.year {
+			t.Errorf("Decode(%s) model year %d, want %d", tt.vin, info.ModelYear, tt.year)
+		}
+		if got := info.WMI + info.VDS + info.CheckDigit + tt.vin[9:10] + info.Plant + info.Serial; got != tt.vin {
+			t.Errorf("Decode(%s) sections join to %s", tt.vin, got)
+		}
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		vin    string
+		reason string
+	}{
+		{"1HGCM82633A00435", "17 characters"},
+		{"1HGCM82633A0043521", "17 characters"},
+		{"1HGCM82643A004352", "check digit"},
+		{"5YJ3E1EA7KF317000", "check digit"},
+		{"1HGCM8263IA004352", "not allowed"},
+		{"1HGCM8263OA004352", "not allowed"},
+		{"1hgcm82633a004352", "not allowed"},
+		{"1HGCM82690A004352", "model year"},
+		{"1HGCM8261UA004352", "model year"},
+	}
+
+	for _, tt := range tests {
+		err := Validate(tt.vin)
+		if err == nil || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("Validate(%s) = %v, want an error about %s", tt.vin, err, tt.reason)
+		}
+	}
+}
+
+func TestTransliteration(t *testing.T) {
+	// Letters run 1-9 from A, J and S, skipping I, O and Q
+	for _, run := range []string{"ABCDEFGH", "JKLMN-P-R", "-STUVWXYZ"} {
+		for i := 0; i < len(run); i++ {
+			if run[i] == '-' {
+				continue
+			}
+			if v, ok := value(run[i]); !ok || v != i+1 {
+				t.Errorf("value(%c) = %d, %v; want %d", run[i], v, ok, i+1)
+			}
+		}
+	}
+	for _, c := range []byte("IOQ") {
+		if _, ok := value(c); ok {
+			t.Errorf("value(%c) should not be allowed", c)
+		}
+	}
+}
+
+func TestValidateWMI(t *testing.T) {
+	for _, wmi := range []string{"1HG", "JH4", "5YJ"} {
+		if err := ValidateWMI(wmi); err != nil {
+			t.Errorf("ValidateWMI(%s): %v", wmi, err)
+		}
+	}
+	for _, wmi := range []string{"", "1H", "1HGC", "1HQ"} {
+		if err := ValidateWMI(wmi); err == nil {
+			t.Errorf("ValidateWMI(%q) should fail", wmi)
+		}
+	}
+}
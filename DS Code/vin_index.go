@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+	"os"
+	"time"
+)
+
+const vinBucket = "vins"
+
+// indexVersion is stored in the blocks bucket under indexVersionKey. Bump it
+// whenever indexed records change shape so that indexes written by an older
+// version are rebuilt when the chain is opened.
+const indexVersion = "13"
+const indexVersionKey = "index"
+
+// indexBuckets hold everything derived from the blocks, rebuilt together by Reindex
+var indexBuckets = []string{vinBucket, manufacturerBucket, roleBucket, recallBucket, ownerBucket, txBucket}
+
+// TxPosition locates a transaction in the chain
+type TxPosition struct {
+	BlockHash []byte
+	Index     int
+}
+
+// VehicleRecord is the per-VIN summary kept in the VIN index so ownership and
+// loan checks don't have to walk the chain.
+type VehicleRecord struct {
+	VIN          string
+	Owner        []byte
+	Acquired     int64  // When the current owner took the vehicle
+	PricePaid    int    // What the current owner paid; 0 unless bought
+	Registered   bool   // The current owner has registered the vehicle
+	Liens        []Lien // Every loan secured on the vehicle, released or not
+	LastOdometer int
+	Policies     []InsurancePolicy
+	Claims       []InsuranceClaim
+	Brands       []string     // Title brands, which stay with the vehicle for good
+	Deregistered string       // Reason the VIN was retired; empty while it is in use
+	Theft        *TheftReport // Open theft report; nil unless the vehicle is missing
+	Remedied     []string     // Recall campaigns whose fix has been carried out
+	Positions    []TxPosition // Every transaction touching the VIN, oldest first
+}
+
+// apply records the effect of a transaction on the vehicle
+func (r *VehicleRecord) apply(tx Transaction) {
+	switch tx := tx.(type) {
+	case *ManufacturerIssue:
+		r.Owner = tx.Manufacturer
+		r.Acquired = tx.IssueDate
+	case *VehicleRegistration:
+		// Registering never changes hands; only the first registration of a
+		// VIN on a chain without an authority gives it an owner
+		if r.Owner == nil {
+			r.Owner = tx.Owner
+			r.Acquired = tx.RegistrationDate
+		}
+		r.Registered = true
+	case *VehicleSale:
+		r.Owner = tx.Buyer
+		r.Acquired = tx.SaleDate
+		r.PricePaid = tx.Price
+		r.Registered = false
+		if lien := r.lien(tx.Lien); lien != nil {
+			lien.Paid += tx.Payoff
+			lien.Released = true
+			lien.PaidOffAtSale = true
+		}
+		if tx.BuyerLoan != nil {
+			r.Liens = append(r.Liens, Lien{Loan: TransactionHash(tx.BuyerLoan), Contract: *tx.BuyerLoan, Refinances: tx.Lien})
+		}
+	case *LoanContract:
+		r.Liens = append(r.Liens, Lien{Loan: TransactionHash(tx), Contract: *tx})
+	case *LoanPayment:
+		if lien := r.lien(tx.Loan); lien != nil {
+			lien.Paid += tx.Amount
+			lien.Payments = append(lien.Payments, *tx)
+		}
+	case *LienRelease:
+		if lien := r.lien(tx.Loan); lien != nil {
+			lien.Released = true
+		}
+	case *ServiceRecord:
+		r.LastOdometer = tx.Odometer
+	case *InsurancePolicy:
+		r.Policies = append(r.Policies, *tx)
+	case *InsuranceClaim:
+		r.Claims = append(r.Claims, *tx)
+	case *TitleBrand:
+		r.Brands = append(r.Brands, tx.Brand)
+	case *Deregistration:
+		r.Deregistered = tx.Reason
+	case *TheftReport:
+		r.Theft = tx
+	case *TheftRecovery:
+		r.Theft = nil
+	case *RecallRemedy:
+		r.Remedied = append(r.Remedied, tx.Campaign)
+	}
+}
+
+// remedied reports whether the fix for a recall campaign has been carried out
+func (r *VehicleRecord) remedied(campaign string) bool {
+	for _, c := range r.Remedied {
+		if c == campaign {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasBrand reports whether the title carries brand
+func (r *VehicleRecord) hasBrand(brand string) bool {
+	for _, b := range r.Brands {
+		if b == brand {
+			return true
+		}
+	}
+
+	return false
+}
+
+// policy returns the vehicle's policy with the given number, or nil
+func (r *VehicleRecord) policy(policyID string) *InsurancePolicy {
+	for i := range r.Policies {
+		if r.Policies[i].PolicyID == policyID {
+			return &r.Policies[i]
+		}
+	}
+
+	return nil
+}
+
+// CoverageOn returns the policies in force at time t
+func (r *VehicleRecord) CoverageOn(t int64) []InsurancePolicy {
+	var covering []InsurancePolicy
+	for _, p := range r.Policies {
+		if p.covers(t) {
+			covering = append(covering, p)
+		}
+	}
+
+	return covering
+}
+
+// Lien is a loan secured on the vehicle and what has happened to it since.
+// It stays active until the lender releases it, whatever the contract's dates.
+type Lien struct {
+	Loan          []byte // Hash of the LoanContract, which payments and the release refer to
+	Contract      LoanContract
+	Paid          int
+	Payments      []LoanPayment // In the order they were recorded
+	Released      bool
+	PaidOffAtSale bool   // Released by the lender's consent to a sale
+	Refinances    []byte // Loan this one paid off when the buyer financed the purchase
+}
+
+// Status describes where the lien stands
+func (l *Lien) Status() string {
+	switch {
+	case l.PaidOffAtSale:
+		return "paid off at sale"
+	case l.Released:
+		return "released"
+	default:
+		return "active"
+	}
+}
+
+// lien returns the lien created by the loan with the given hash, or nil
+func (r *VehicleRecord) lien(loan []byte) *Lien {
+	for i := range r.Liens {
+		if bytes.Equal(r.Liens[i].Loan, loan) {
+			return &r.Liens[i]
+		}
+	}
+
+	return nil
+}
+
+// activeLiens returns the liens the lender has not released yet
+func (r *VehicleRecord) activeLiens() []*Lien {
+	var active []*Lien
+	for i := range r.Liens {
+		if !r.Liens[i].Released {
+			active = append(active, &r.Liens[i])
+		}
+	}
+
+	return active
+}
+
+func (r *VehicleRecord) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	if err := encoder.Encode(r); err != nil {
+		log.Panic(err)
+	}
+
+	return result.Bytes()
+}
+
+func DeserializeVehicleRecord(data []byte) (*VehicleRecord, error) {
+	var r VehicleRecord
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode vehicle record: %w", err)
+	}
+
+	return &r, nil
+}
+
+// FindVehicle returns the indexed record for vin, or nil if the VIN is not on chain
+func (bc *Blockchain) FindVehicle(vin string) *VehicleRecord {
+	var record *VehicleRecord
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(vinBucket)).Get([]byte(vin))
+		if data == nil {
+			return nil
+		}
+
+		var err error
+		record, err = DeserializeVehicleRecord(data)
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return record
+}
+
+// StolenVehicles returns the records of every vehicle with an open theft report
+func (bc *Blockchain) StolenVehicles() []*VehicleRecord {
+	var stolen []*VehicleRecord
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(vinBucket)).ForEach(func(k, data []byte) error {
+			record, err := DeserializeVehicleRecord(data)
+			if err != nil {
+				return err
+			}
+			if record.State() == Stolen {
+				stolen = append(stolen, record)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return stolen
+}
+
+// indexBlock updates the VIN index for every transaction in block. It runs
+// inside the same bolt transaction that stores the block.
+func indexBlock(tx *bolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(vinBucket))
+
+	for i, t := range block.Transactions {
+		if err := putTransaction(tx, t, block.Hash); err != nil {
+			return err
+		}
+
+		// Chain-level records and recalls go to their own indexes instead
+		switch t := t.(type) {
+		case *genesis:
+			if err := putAuthority(tx, t.Authority); err != nil {
+				return err
+			}
+			continue
+		case *ManufacturerAuthorization:
+			if err := putManufacturer(tx, t); err != nil {
+				return err
+			}
+			continue
+		case *RoleGrant:
+			if err := putRole(tx, t); err != nil {
+				return err
+			}
+			continue
+		case *RecallNotice:
+			if err := putRecall(tx, t); err != nil {
+				return err
+			}
+			continue
+		}
+
+		record := &VehicleRecord{VIN: t.ID()}
+		if data := b.Get([]byte(t.ID())); data != nil {
+			var err error
+			if record, err = DeserializeVehicleRecord(data); err != nil {
+				return err
+			}
+		}
+
+		previous := record.Owner
+		record.apply(t)
+		record.Positions = append(record.Positions, TxPosition{block.Hash, i})
+
+		if err := b.Put([]byte(t.ID()), record.Serialize()); err != nil {
+			return err
+		}
+		if err := moveOwner(tx, previous, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putIndexVersion marks the indexes as written in the current layout
+func putIndexVersion(tx *bolt.Tx) error {
+	return tx.Bucket([]byte(blocksBucket)).Put([]byte(indexVersionKey), []byte(indexVersion))
+}
+
+// Reindex rebuilds the VIN, owner, manufacturer, role, recall and transaction indexes from genesis, for
+// databases created before they existed or in an older layout
+func (bc *Blockchain) Reindex() {
+	blocks, err := bc.BlocksFromGenesis()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	start := time.Now()
+	err = bc.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range indexBuckets {
+			if err := tx.DeleteBucket([]byte(name)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		for _, block := range blocks {
+			if err := indexBlock(tx, block); err != nil {
+				return err
+			}
+		}
+
+		return putIndexVersion(tx)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Indexed %d blocks in %s\n", len(blocks), time.Since(start))
+}